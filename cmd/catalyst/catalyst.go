@@ -2,10 +2,12 @@ package main
 
 import (
 	"os"
+	"strings"
 	"syscall"
 
 	"github.com/livepeer/catalyst/cmd/downloader/cli"
 	"github.com/livepeer/catalyst/cmd/downloader/downloader"
+	"github.com/livepeer/catalyst/cmd/downloader/manifest"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
 	glog "github.com/magicsong/color-glog"
 )
@@ -21,6 +23,10 @@ func main() {
 		glog.Fatalf("error parsing cli flags: %s", err)
 		return
 	}
+	if len(cliFlags.Command) > 0 {
+		runCommand(cliFlags)
+		return
+	}
 	err = downloader.Run(cliFlags)
 	if err != nil {
 		glog.Fatalf("error running downloader: %s", err)
@@ -28,6 +34,33 @@ func main() {
 	execNext(cliFlags)
 }
 
+// runCommand handles `catalyst <command>` invocations, which exit
+// instead of downloading.
+func runCommand(cliFlags types.CliFlags) {
+	var err error
+	switch strings.Join(cliFlags.Command, " ") {
+	case "verify":
+		err = downloader.Verify(cliFlags)
+	case "gc":
+		err = downloader.GC(cliFlags)
+	case "outdated":
+		err = manifest.Outdated(cliFlags)
+	case "manifest validate":
+		err = manifest.Validate(cliFlags)
+	case "manifest schema":
+		err = manifest.PrintSchema(cliFlags)
+	case "manifest migrate":
+		err = manifest.Migrate(cliFlags)
+	default:
+		glog.Fatalf("unknown command %q", strings.Join(cliFlags.Command, " "))
+	}
+	if err != nil {
+		glog.Errorf("%s failed: %s", strings.Join(cliFlags.Command, " "), err)
+		glog.Flush()
+		os.Exit(1)
+	}
+}
+
 // Done! Move on to the provided next application, if it exists.
 func execNext(cliFlags types.CliFlags) {
 	if len(cliFlags.ExecCommand) == 0 {
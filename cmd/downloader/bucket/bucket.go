@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
 	"github.com/livepeer/catalyst/cmd/downloader/utils"
 	glog "github.com/magicsong/color-glog"
@@ -18,15 +20,32 @@ func GetArtifactVersion(buildInfo types.BuildManifestInformation) string {
 	return buildInfo.Commit
 }
 
+// DefaultBase is the root of the bucket for services that don't set
+// their own `baseURL`.
+var DefaultBase = constants.BucketBase
+
+// BaseURL returns the root of the bucket the builds of a service are
+// fetched from.
+func BaseURL(service *types.Service) string {
+	if service.Strategy.BaseURL != "" {
+		return strings.TrimSuffix(service.Strategy.BaseURL, "/")
+	}
+	return strings.TrimSuffix(DefaultBase, "/")
+}
+
 // GetBuildInformation pulls in build manifest from bucket.
-func GetBuildInformation(release, project string) (*types.BuildManifestInformation, error) {
+func GetBuildInformation(base, release, project string) (*types.BuildManifestInformation, error) {
 	var buildInfo *types.BuildManifestInformation
-	url := fmt.Sprintf(constants.BucketManifestURLFormat, project, release)
-	glog.V(6).Infof("fetching manifest data for project=%s from url=%s", project, url)
-	resp, err := http.Get(url)
+	url := base + fmt.Sprintf(constants.BucketManifestPathFormat, project, release)
+	glog.V(6).Infof("fetching manifest data for project=%s from url=%s", project, network.Redact(url))
+	resp, err := network.Get(url)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d while fetching build information from %s", resp.StatusCode, network.Redact(url))
+	}
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -38,30 +57,35 @@ func GetBuildInformation(release, project string) (*types.BuildManifestInformati
 }
 
 // GenerateArtifactURL wraps a `fmt.Sprintf` to template
-func GenerateArtifactURL(project, version, fileName string) string {
-	return fmt.Sprintf(constants.BucketDownloadURLFormat, project, version, fileName)
+func GenerateArtifactURL(base, project, version, fileName string) string {
+	return base + fmt.Sprintf(constants.BucketDownloadPathFormat, project, version, fileName)
 }
 
 // GetArtifactInfo generates a structure of all necessary information
 // from the Google Cloud Storage bucket
-func GetArtifactInfo(platform, architecture, release string, service *types.Service) *types.ArtifactInfo {
+func GetArtifactInfo(platform, architecture, release string, service *types.Service) (*types.ArtifactInfo, error) {
 	if len(service.Release) == 0 {
-		glog.Fatalf("Bucket type strategy requires a branch name as `release` value. Found %s at root", release)
-		panic("")
+		return nil, fmt.Errorf("%s: bucket type strategy requires a branch name as `release` value, found %s at root", service.Name, release)
 	}
 
 	project := service.Strategy.Project
+	base := BaseURL(service)
 	release = utils.CleanBranchName(service.Release)
-	buildInfo, err := GetBuildInformation(release, project)
-	if err != nil {
-		glog.Errorf("error when processing service=%s", service.Name)
-		glog.Fatal(err)
-	}
 	commit := service.Strategy.Commit
+	// A pinned commit is all the URLs need, the build information of the
+	// branch is only fetched for its head commit. Pinned services that
+	// name no files use the default artifact names.
 	if commit == "" {
+		buildInfo, err := GetBuildInformation(base, release, project)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", service.Name, err)
+		}
 		commit = GetArtifactVersion(*buildInfo)
+		service.Strategy.Commit = commit
+		if buildInfo.SrcFilenames != nil && service.SrcFilenames == nil && service.SrcFilename == "" && service.Artifacts == nil && service.Artifact == nil {
+			service.SrcFilenames = buildInfo.SrcFilenames
+		}
 	}
-	service.Strategy.Commit = buildInfo.Commit
 
 	var info = &types.ArtifactInfo{
 		Name:         service.Name,
@@ -70,37 +94,32 @@ func GetArtifactInfo(platform, architecture, release string, service *types.Serv
 		Version:      commit,
 	}
 
-	extension := utils.PlatformExt(platform)
+	artifact, err := service.ArtifactFor(platform, architecture)
+	if err != nil {
+		return nil, err
+	}
 	packageName := fmt.Sprintf("livepeer-%s", service.Name)
 	if len(service.Binary) > 0 {
 		packageName = service.Binary
 	}
-	info.ArchiveFileName = fmt.Sprintf("%s-%s-%s.%s", packageName, info.Platform, info.Architecture, extension)
-	if buildInfo.SrcFilenames != nil && service.SrcFilenames == nil {
-		service.SrcFilenames = buildInfo.SrcFilenames
-	}
-
-	if service.SrcFilenames != nil {
+	if service.SrcFilenames != nil || service.SrcFilename != "" || service.Artifacts != nil || service.Artifact != nil {
 		packageName = service.Name
-		platArch := fmt.Sprintf("%s-%s", platform, architecture)
-		name, ok := service.SrcFilenames[platArch]
-		if !ok {
-			panic(fmt.Errorf("%s build not found in srcFilenames for %s", service.Name, platArch))
-		}
-		info.ArchiveFileName = name
 	}
 	info.Binary = packageName
-	info.ArchiveURL = GenerateArtifactURL(project, info.Version, info.ArchiveFileName)
+	info.ArchiveFileName = artifact.File
+	info.Digest = artifact.Digest
+	info.Extract = artifact.Extract
+	info.ArchiveURL = GenerateArtifactURL(base, project, info.Version, info.ArchiveFileName)
 
 	if !service.SkipChecksum {
 		info.ChecksumFileName = fmt.Sprintf("%s_%s", info.Version, constants.ChecksumFileSuffix)
-		info.ChecksumURL = GenerateArtifactURL(project, info.Version, info.ChecksumFileName)
+		info.ChecksumURL = GenerateArtifactURL(base, project, info.Version, info.ChecksumFileName)
 	}
 
 	if !service.SkipGPG {
 		info.SignatureFileName = fmt.Sprintf("%s.%s", info.ArchiveFileName, constants.SignatureFileExtension)
-		info.SignatureURL = GenerateArtifactURL(project, info.Version, info.SignatureFileName)
+		info.SignatureURL = GenerateArtifactURL(base, project, info.Version, info.SignatureFileName)
 	}
 
-	return info
+	return info, nil
 }
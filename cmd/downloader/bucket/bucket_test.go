@@ -1,27 +1,53 @@
 package bucket
 
 import (
-	"runtime"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
 )
 
-// func TestTagInformation(t *testing.T) {
-// 	projects := []string{
-// 		"livepeer/stream-tester",
-// 	}
-// 	for _, project := range projects {
-// 		_, err := GetLatestRelease(project)
-// 		if err != nil {
-// 			t.Error("could not fetch tag information")
-// 			t.Fail()
-// 		}
-// 	}
-// }
+// TestMain keeps the tests off the real bucket, those that don't use
+// the fixtures fail instead.
+func TestMain(m *testing.M) {
+	DefaultBase = "https://build.livepeer.invalid"
+	os.Exit(m.Run())
+}
+
+// useFixtures points the bucket at a fake that serves the build
+// information in testdata, and counts the requests it gets.
+func useFixtures(t *testing.T) *int {
+	requests := 0
+	files := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	require.NoError(t, network.Configure(network.Options{AllowHTTP: []string{"127.0.0.1"}}))
+	t.Cleanup(func() { network.Configure(network.Options{}) })
+	previous := DefaultBase
+	DefaultBase = server.URL
+	t.Cleanup(func() { DefaultBase = previous })
+	return &requests
+}
+
+func TestBuildInformation(t *testing.T) {
+	useFixtures(t)
+	buildInfo, err := GetBuildInformation(DefaultBase, "catalyst", "mistserver")
+	require.NoError(t, err)
+	require.Equal(t, "6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1", buildInfo.Commit)
+	_, err = GetBuildInformation(DefaultBase, "unknown", "mistserver")
+	require.ErrorContains(t, err, "HTTP 404")
+}
 
 func TestArtifactInfo(t *testing.T) {
+	useFixtures(t)
 	serviceInfo := &types.Service{
 		Name:    "mistserver",
 		Release: "catalyst",
@@ -30,9 +56,64 @@ func TestArtifactInfo(t *testing.T) {
 			Project:  "mistserver",
 		},
 	}
-	info := GetArtifactInfo(runtime.GOOS, runtime.GOARCH, constants.LatestTagReleaseName, serviceInfo)
-	if info.Version == "" {
-		t.Error("The binary name generated for service doesn't match")
-		t.Fail()
+	info, err := GetArtifactInfo("linux", "amd64", constants.LatestTagReleaseName, serviceInfo)
+	require.NoError(t, err)
+	require.Equal(t, "6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1", info.Version)
+	require.Equal(t, DefaultBase+"/mistserver/6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1/livepeer-mistserver-linux-amd64.tar.gz", info.ArchiveURL)
+	require.Equal(t, DefaultBase+"/mistserver/6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1/6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1_checksums.txt", info.ChecksumURL)
+}
+
+func TestArtifactInfoPinnedCommit(t *testing.T) {
+	requests := useFixtures(t)
+	serviceInfo := &types.Service{
+		Name:    "mistserver",
+		Release: "catalyst",
+		Strategy: &types.DownloadStrategy{
+			Download: "bucket",
+			Project:  "mistserver",
+			Commit:   "6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1",
+			BaseURL:  "https://mirror.example.com/builds/",
+		},
+		SrcFilenames: map[string]string{
+			"linux-amd64": "livepeer-mistserver-linux-amd64.tar.gz",
+		},
 	}
+	info, err := GetArtifactInfo("linux", "amd64", constants.LatestTagReleaseName, serviceInfo)
+	require.NoError(t, err)
+	require.Zero(t, *requests, "a pinned commit needs no build information")
+	require.Equal(t, "6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1", info.Version)
+	require.Equal(t, "https://mirror.example.com/builds/mistserver/6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1/livepeer-mistserver-linux-amd64.tar.gz", info.ArchiveURL)
+	require.Equal(t, "6ce2ef1b0e4ba0cf1f01c33ec46d0a7a9cbd7ec1", serviceInfo.Strategy.Commit)
+}
+
+func TestArtifactInfoPinnedCommitWithDefaultNames(t *testing.T) {
+	requests := useFixtures(t)
+	serviceInfo := &types.Service{
+		Name:    "catalyst-api",
+		Release: "main",
+		Strategy: &types.DownloadStrategy{
+			Download: "bucket",
+			Project:  "catalyst-api",
+			// Not the head of the branch, which moved on
+			Commit: "a4b62c993e4160a45e3865644d5db0f5133d511e",
+		},
+	}
+	info, err := GetArtifactInfo("linux", "arm64", constants.LatestTagReleaseName, serviceInfo)
+	require.NoError(t, err)
+	require.Zero(t, *requests, "a pinned commit needs no build information")
+	require.Equal(t, DefaultBase+"/catalyst-api/a4b62c993e4160a45e3865644d5db0f5133d511e/livepeer-catalyst-api-linux-arm64.tar.gz", info.ArchiveURL)
+}
+
+func TestArtifactInfoErrors(t *testing.T) {
+	useFixtures(t)
+	serviceInfo := &types.Service{
+		Name:     "mistserver",
+		Strategy: &types.DownloadStrategy{Download: "bucket", Project: "mistserver"},
+	}
+	_, err := GetArtifactInfo("linux", "amd64", constants.LatestTagReleaseName, serviceInfo)
+	require.EqualError(t, err, "mistserver: bucket type strategy requires a branch name as `release` value, found latest at root")
+
+	serviceInfo.Release = "unknown"
+	_, err = GetArtifactInfo("linux", "amd64", constants.LatestTagReleaseName, serviceInfo)
+	require.ErrorContains(t, err, "HTTP 404")
 }
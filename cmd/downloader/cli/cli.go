@@ -6,9 +6,15 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
+	"github.com/livepeer/catalyst/cmd/downloader/bucket"
 	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
 	"github.com/livepeer/catalyst/cmd/downloader/utils"
 	glog "github.com/magicsong/color-glog"
@@ -23,17 +29,36 @@ func validateFlags(flags *types.CliFlags) error {
 			flags.Architecture,
 		)
 	}
-	if !utils.IsFileExists(flags.ManifestFile) {
+	if flags.ManifestFile != "-" && !utils.IsFileExists(flags.ManifestFile) {
 		manifestURL, err := url.Parse(flags.ManifestFile)
 		if err != nil {
 			return err
 		}
-		if manifestURL.Scheme == "https" {
+		switch {
+		case manifestURL.Scheme == "https" || manifestURL.Scheme == "http" || manifestURL.Scheme == "s3":
+			// Plaintext http needs -allow-http
 			flags.ManifestURL = true
-		} else if len(flags.ExecCommand) == 0 {
+		case manifestURL.Scheme == "file" && (manifestURL.Host == "" || manifestURL.Host == "localhost"):
+			flags.ManifestFile = filepath.FromSlash(manifestURL.Path)
+		case len(flags.ExecCommand) == 0 && len(flags.Command) == 0:
 			return errors.New("invalid path/url to manifest file")
 		}
 	}
+	for _, overlay := range flags.ManifestOverlays {
+		if !utils.IsFileExists(overlay) {
+			return fmt.Errorf("manifest overlay %s not found", overlay)
+		}
+	}
+	err := network.Configure(network.Options{
+		CABundle:    flags.CABundle,
+		ClientCert:  flags.ClientCert,
+		ClientKey:   flags.ClientKey,
+		AllowHTTP:   flags.AllowHTTP,
+		Credentials: flags.Credentials,
+	})
+	if err != nil {
+		return err
+	}
 	if info, err := os.Stat(flags.DownloadPath); !(err == nil && info.IsDir()) {
 		err = os.MkdirAll(flags.DownloadPath, os.ModePerm)
 		if err != nil {
@@ -43,6 +68,18 @@ func validateFlags(flags *types.CliFlags) error {
 	return nil
 }
 
+// stringList is a flag that can be passed multiple times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 // GetCliFlags reads command-line arguments and generates a struct
 // with useful values set after parsing the same.
 func GetCliFlags(buildFlags types.BuildFlags) (types.CliFlags, error) {
@@ -56,6 +93,11 @@ func GetCliFlags(buildFlags types.BuildFlags) (types.CliFlags, error) {
 		}
 		args = append(args, arg)
 	}
+	// Leading non-flag arguments name a subcommand, e.g. `catalyst verify`
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cliFlags.Command = append(cliFlags.Command, args[0])
+		args = args[1:]
+	}
 	flag.Set("logtostderr", "true")
 	vFlag := flag.Lookup("v")
 	fs := flag.NewFlagSet(constants.AppName, flag.ExitOnError)
@@ -74,11 +116,44 @@ func GetCliFlags(buildFlags types.BuildFlags) (types.CliFlags, error) {
 	fs.StringVar(&cliFlags.Platform, "platform", goos, "One of linux/windows/darwin")
 	fs.StringVar(&cliFlags.Architecture, "architecture", goarch, "System architecture (amd64/arm64)")
 	fs.StringVar(&cliFlags.DownloadPath, "path", fmt.Sprintf(".%sbin", string(os.PathSeparator)), "Path to store binaries")
-	fs.StringVar(&cliFlags.ManifestFile, "manifest", "manifest.yaml", "Path (or URL) to manifest yaml file")
+	fs.StringVar(&cliFlags.ManifestFile, "manifest", "manifest.yaml", "Path, file://, https://, http:// (with -allow-http) or s3://<bucket>/<key> URL of the manifest, or - for stdin. YAML, JSON or TOML")
+	fs.Var((*stringList)(&cliFlags.ManifestOverlays), "manifest-overlay", "Path to a manifest overlay applied on top of the manifest. Can be repeated, later overlays win")
+	fs.Var((*stringList)(&cliFlags.Only), "only", "Comma-separated services to install, even if the manifest skips them")
+	fs.Var((*stringList)(&cliFlags.Group), "group", "Comma-separated groups or tags of services to install")
+	fs.Var((*stringList)(&cliFlags.Exclude), "exclude", "Comma-separated services, groups or tags not to install")
+	githubAPI := constants.GitHubAPIBase
+	if os.Getenv("GITHUB_API_URL") != "" {
+		githubAPI = os.Getenv("GITHUB_API_URL")
+	}
+	fs.StringVar(&cliFlags.GitHubAPI, "github-api", githubAPI, "Base URL of the GitHub API, for GitHub Enterprise (https://<host>/api/v3) or local fakes. Requests authenticate with GITHUB_TOKEN when set")
+	fs.StringVar(&cliFlags.GitHubDownload, "github-download-url", "", "Root of the release downloads of github services, e.g. a mirror laid out as <owner>/<repo>/releases/download/<tag>/<file>. Defaults to the web root of -github-api")
+	fs.StringVar(&cliFlags.BucketURL, "bucket-url", constants.BucketBase, "Root of the bucket builds of bucket services are fetched from, e.g. a mirror")
+	fs.StringVar(&cliFlags.CABundle, "ca-bundle", "", "PEM file of certificates to trust on top of the system ones, e.g. of a private CA")
+	fs.StringVar(&cliFlags.ClientCert, "client-cert", "", "PEM client certificate to present to servers asking for one, e.g. private mirrors")
+	fs.StringVar(&cliFlags.ClientKey, "client-key", "", "PEM key of -client-cert")
+	fs.Var((*stringList)(&cliFlags.AllowHTTP), "allow-http", "Comma-separated hosts (with an optional port) to allow fetching plaintext http:// artifacts from")
+	fs.Var((*stringList)(&cliFlags.Credentials), "credential", "Credential of a host as <host>=bearer:<token>, basic:<user>:<password>, netrc or helper:<name> (runs catalyst-credential-<name>). Values starting with $ are read from that environment variable. Can be repeated")
+	s3Endpoint := network.S3Endpoint
+	for _, variable := range []string{"AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_S3"} {
+		if os.Getenv(variable) != "" {
+			s3Endpoint = os.Getenv(variable)
+		}
+	}
+	fs.StringVar(&cliFlags.S3Endpoint, "s3-endpoint", s3Endpoint, "S3 compatible API that s3:// manifests are fetched from, e.g. http://127.0.0.1:9000 for a local MinIO (with -allow-http). Credentials are read from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or ~/.aws/credentials")
+	fs.BoolVar(&cliFlags.InsecureManifest, "insecure-manifest", false, "Use remote manifests without a valid signature, or that turn off the verification of a service. Unsafe")
+	fs.BoolVar(&cliFlags.AllowRemoteHooks, "allow-remote-hooks", false, "Run the preInstall, postInstall and check hooks of remote manifests. Not implied by -insecure-manifest. Unsafe")
+	fs.DurationVar(&cliFlags.LockTimeout, "lock-timeout", 15*time.Minute, "How long to wait for another process installing into -path to finish")
 	fs.BoolVar(&cliFlags.SkipDownloaded, "skip-downloaded", false, "Skip already downloaded archive (if found)")
-	fs.BoolVar(&cliFlags.Cleanup, "cleanup", true, "Cleanup downloaded archives after extraction")
+	fs.BoolVar(&cliFlags.Cleanup, "cleanup", true, "Remove the archives, signatures and checksums a run downloaded once they're extracted. Kept ones are pruned with `catalyst gc`")
 	fs.BoolVar(&cliFlags.UpdateManifest, "update-manifest", false, "Update the manifest file commit shas from releases prior to downloading")
+	fs.StringVar(&cliFlags.UpdateSummary, "update-summary", "", "Write a markdown summary of the services -update-manifest bumped to this file, - for stdout")
 	fs.BoolVar(&cliFlags.Download, "download", true, "Actually do a download. Only useful for -update-manifest=true -download=false")
+	fs.StringVar(&cliFlags.MaxGlibc, "max-glibc", "", "Newest glibc version available on the target system (e.g. 2.35). Linux binaries requiring a newer one are rejected")
+	fs.BoolVar(&cliFlags.JSON, "json", false, "Print the report of commands such as outdated as JSON")
+	fs.Var((*stringList)(&cliFlags.VerifyIgnore), "verify-ignore", "Comma-separated glob patterns of files in -path that verify should not report as unexpected. Can be repeated")
+	fs.IntVar(&cliFlags.GCKeep, "gc-keep", 1, "Number of versions of each service that gc keeps staged, besides the installed one. 0 keeps them all")
+	fs.DurationVar(&cliFlags.GCMaxAge, "gc-max-age", 0, "Make gc also remove staged versions older than this, e.g. 720h. 0 removes by count only")
+	fs.BoolVar(&cliFlags.VerifyRehash, "verify-rehash", false, "Make verify re-record the current hashes of installed files instead of checking them")
 
 	version := fs.Bool("version", false, "Get version information")
 
@@ -101,5 +176,12 @@ func GetCliFlags(buildFlags types.BuildFlags) (types.CliFlags, error) {
 	if err != nil {
 		glog.Fatal(err)
 	}
+	github.DefaultClient = github.NewClient(cliFlags.GitHubAPI, os.Getenv("GITHUB_TOKEN"))
+	github.DownloadBase = cliFlags.GitHubDownload
+	bucket.DefaultBase = cliFlags.BucketURL
+	utils.InsecureManifest = cliFlags.InsecureManifest
+	utils.AllowRemoteHooks = cliFlags.AllowRemoteHooks
+	utils.ManifestCacheDir = cliFlags.DownloadPath
+	network.S3Endpoint = cliFlags.S3Endpoint
 	return cliFlags, err
 }
@@ -1,16 +1,30 @@
 package constants
 
 const (
-	AppName                 = "catalyst"
-	LatestTagReleaseName    = "latest"
-	SignatureFileExtension  = "sig"
-	ChecksumFileSuffix      = "checksums.txt"
-	TaggedDownloadURLFormat = "https://github.com/%s/releases/download/%s/%s"
-	BucketDownloadURLFormat = "https://build.livepeer.live/%s/%s/%s"
-	BucketManifestURLFormat = "https://build.livepeer.live/%s/%s.json"
-	PGPKeyFingerprint       = "A2F9039A8603C44C21414432A2224D4537874DB2"
-	ZipFileExtension        = "zip"
-	TarFileExtension        = "tar.gz"
+	AppName                       = "catalyst"
+	LatestTagReleaseName          = "latest"
+	SignatureFileExtension        = "sig"
+	ChecksumFileSuffix            = "checksums.txt"
+	GitHubAPIBase                 = "https://api.github.com"
+	GitHubWebBase                 = "https://github.com"
+	TaggedDownloadPathFormat      = "/%s/releases/download/%s/%s"
+	GitHubComparePathFormat       = "/%s/compare/%s...%s"
+	GitHubLatestReleasePathFormat = "/repos/%s/releases/latest"
+	GitHubReleasesPathFormat      = "/repos/%s/releases?per_page=100"
+	GitHubReleaseByTagPathFormat  = "/repos/%s/releases/tags/%s"
+	GitHubTagRefPathFormat        = "/repos/%s/git/ref/tags/%s"
+	GitHubTagObjectPathFormat     = "/repos/%s/git/tags/%s"
+	GitHubCompareAPIPathFormat    = "/repos/%s/compare/%s...%s"
+	BucketBase                    = "https://build.livepeer.live"
+	BucketDownloadPathFormat      = "/%s/%s/%s"
+	BucketManifestPathFormat      = "/%s/%s.json"
+	PGPKeyFingerprint             = "A2F9039A8603C44C21414432A2224D4537874DB2"
+	ZipFileExtension              = "zip"
+	TarFileExtension              = "tar.gz"
+	InventoryFileName             = ".catalyst-inventory.json"
+	ManifestCacheFileName         = ".catalyst-manifest-cache.json"
+	LockFileName                  = ".catalyst.lock"
+	StagingDirName                = ".catalyst-staging"
 )
 
 const PGPPublicKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/livepeer/catalyst/cmd/downloader/cli"
 	"github.com/livepeer/catalyst/cmd/downloader/downloader"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
@@ -15,6 +17,9 @@ func main() {
 		glog.Fatalf("error parsing cli flags: %s", err)
 		return
 	}
+	if len(cliFlags.Command) > 0 {
+		glog.Fatalf("the downloader takes no commands, use `catalyst %s` instead", strings.Join(cliFlags.Command, " "))
+	}
 	err = downloader.Run(cliFlags)
 	if err != nil {
 		glog.Fatalf("error running downloader: %s", err)
@@ -7,15 +7,18 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/livepeer/catalyst/cmd/downloader/bucket"
 	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/hooks"
+	"github.com/livepeer/catalyst/cmd/downloader/inventory"
+	"github.com/livepeer/catalyst/cmd/downloader/lock"
 	"github.com/livepeer/catalyst/cmd/downloader/manifest"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
 	"github.com/livepeer/catalyst/cmd/downloader/utils"
@@ -24,183 +27,285 @@ import (
 )
 
 // DownloadService works on downloading services for the box to
-// machine and extracting the required binaries from artifacts.
-func DownloadService(flags types.CliFlags, manifest *types.BoxManifest, service *types.Service) error {
+// machine and extracting the required binaries from artifacts. It
+// returns the paths of all extracted files.
+func DownloadService(flags types.CliFlags, manifest *types.BoxManifest, service *types.Service) ([]string, error) {
 	var projectInfo *types.ArtifactInfo
+	var err error
 	platform := flags.Platform
 	architecture := flags.Architecture
 	downloadPath := flags.DownloadPath
 
 	if service.Strategy.Download == "bucket" {
-		projectInfo = bucket.GetArtifactInfo(platform, architecture, manifest.Release, service)
+		projectInfo, err = bucket.GetArtifactInfo(platform, architecture, manifest.Release, service)
 	} else {
-		projectInfo = github.GetArtifactInfo(platform, architecture, manifest.Release, service)
+		projectInfo, err = github.GetArtifactInfo(platform, architecture, manifest.Release, service)
 	}
-	if projectInfo == nil {
-		glog.Fatal("couldn't get project information!")
+	if err != nil {
+		return nil, err
+	}
+	if err := hooks.Run("preInstall", service.PreInstall, service, downloadPath); err != nil {
+		return nil, err
+	}
+	stage, err := newStaging(downloadPath, service.Name, service.Release, service.Strategy.Commit)
+	if err != nil {
+		return nil, err
+	}
+	if flags.Cleanup {
+		// Also after failures, so broken downloads don't linger
+		defer func() {
+			if err := stage.cleanup(); err != nil {
+				glog.Warningf("failed to clean up after %s: %s", service.Name, err)
+			}
+		}()
 	}
 	glog.Infof("will download %s to %q", projectInfo.Name, downloadPath)
-	glog.V(5).Infof("name=%s release=%s commit=%s", projectInfo.Name, service.Release, service.Strategy.Commit)
+	glog.V(5).Infof("name=%s release=%s commit=%s staging=%s", projectInfo.Name, service.Release, service.Strategy.Commit, stage.dir)
 
 	// Download archive
-	archivePath := filepath.Join(downloadPath, projectInfo.ArchiveFileName)
-	err := utils.DownloadFile(archivePath, projectInfo.ArchiveURL, flags.SkipDownloaded)
+	archivePath, err := stage.download(projectInfo.ArchiveFileName, projectInfo.ArchiveURL, flags.SkipDownloaded)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Download signature
 	if !service.SkipGPG {
 		glog.V(3).Infof("verifying GPG signature for service=%s archive=%s file=%s", service.Name, archivePath, projectInfo.SignatureFileName)
-		signaturePath := filepath.Join(downloadPath, projectInfo.SignatureFileName)
-		err = utils.DownloadFile(signaturePath, projectInfo.SignatureURL, flags.SkipDownloaded)
+		signaturePath, err := stage.download(projectInfo.SignatureFileName, projectInfo.SignatureURL, flags.SkipDownloaded)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		err = verification.VerifyGPGSignature(archivePath, signaturePath)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	// Download checksum
 	if !service.SkipChecksum {
 		glog.V(3).Infof("verifying SHA checksum for service=%s file=%s", service.Name, projectInfo.ChecksumFileName)
-		checksumPath := filepath.Join(downloadPath, projectInfo.ChecksumFileName)
-		err = utils.DownloadFile(checksumPath, projectInfo.ChecksumURL, flags.SkipDownloaded)
+		_, err = stage.download(projectInfo.ChecksumFileName, projectInfo.ChecksumURL, flags.SkipDownloaded)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = verification.VerifySHA256Digest(downloadPath, projectInfo.ChecksumFileName)
+		err = verification.VerifySHA256Digest(stage.dir, projectInfo.ChecksumFileName)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	if projectInfo.Size > 0 {
+		err = verification.VerifySize(archivePath, projectInfo.Size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if projectInfo.Digest != "" {
+		glog.V(3).Infof("verifying digest for service=%s file=%s", service.Name, projectInfo.ArchiveFileName)
+		err = verification.VerifyDigest(archivePath, projectInfo.Digest)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	glog.Infof("downloaded %s. Getting ready for extraction!", projectInfo.ArchiveFileName)
+	var extracted []string
 	if strings.HasSuffix(projectInfo.ArchiveFileName, ".zip") {
 		glog.V(7).Info("extracting zip archive!")
-		err = ExtractZipArchive(archivePath, downloadPath, service)
+		extracted, err = ExtractZipArchive(archivePath, downloadPath, projectInfo.Extract)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else if strings.HasSuffix(projectInfo.ArchiveFileName, ".tar.gz") {
 		glog.V(7).Infof("extracting tarball archive!")
-		err = ExtractTarGzipArchive(archivePath, downloadPath, service)
+		extracted, err = ExtractTarGzipArchive(archivePath, downloadPath, projectInfo.Extract)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		glog.V(7).Infof("moving %s to %s!", archivePath, downloadPath)
-		err = MoveBinaryIntoPlace(archivePath, downloadPath, service)
+		extracted, err = MoveBinaryIntoPlace(archivePath, downloadPath, projectInfo.Extract)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+	for _, file := range extracted {
+		err = verification.VerifyBinaryPlatform(file, platform, architecture, flags.MaxGlibc)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return nil
-}
 
-// ExtractZipArchive processes a zip file and extracts a single file
-// from the service definition.
-func ExtractZipArchive(archiveFile, extractPath string, service *types.Service) error {
-	var outputPath string
-	if len(service.ArchivePath) > 0 && !strings.HasSuffix(service.ArchivePath, ".exe") {
-		service.ArchivePath += ".exe"
-		outputPath = filepath.Join(extractPath, service.ArchivePath)
+	// Installed binaries can only be exercised on a matching machine
+	if platform != runtime.GOOS || architecture != runtime.GOARCH {
+		if service.PostInstall != nil || service.Check != nil {
+			glog.Warningf("skipping postInstall and check hooks of %s, cannot run %s-%s binaries here", service.Name, platform, architecture)
+		}
+		return extracted, nil
+	}
+	if err := hooks.Run("postInstall", service.PostInstall, service, downloadPath); err != nil {
+		return nil, err
 	}
-	if len(service.OutputPath) > 0 {
-		outputPath = filepath.Join(extractPath, service.OutputPath+".exe")
+	if err := hooks.Run("check", service.Check, service, downloadPath); err != nil {
+		return nil, err
 	}
+	return extracted, nil
+}
+
+// ExtractZipArchive processes a zip file and extracts the files
+// selected by the artifact's extract rules.
+func ExtractZipArchive(archiveFile, extractPath string, extract []*types.ExtractFile) ([]string, error) {
+	var extracted, entries []string
 	zipReader, err := zip.OpenReader(archiveFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer zipReader.Close()
 	for _, file := range zipReader.File {
-		if strings.HasSuffix(file.Name, service.ArchivePath) {
-			if outputPath == "" {
-				outputPath = filepath.Join(extractPath, file.Name)
-			}
-			glog.V(9).Infof("extracting to %q", outputPath)
-			outfile, err := os.Create(outputPath)
-			if err != nil {
-				return err
-			}
-			reader, _ := file.Open()
-			if _, err := io.Copy(outfile, reader); err != nil {
-				glog.Error("failed to create file")
-			}
-			outfile.Chmod(fs.FileMode(file.Mode()))
-			outfile.Close()
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+		outputPath, ok := extractTarget(extract, file.Name, extractPath)
+		if !ok {
+			continue
+		}
+		glog.V(9).Infof("extracting to %q", outputPath)
+		outfile, err := os.Create(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		reader, _ := file.Open()
+		if _, err := io.Copy(outfile, reader); err != nil {
+			glog.Error("failed to create file")
 		}
+		reader.Close()
+		outfile.Chmod(fs.FileMode(file.Mode()))
+		outfile.Close()
+		extracted = append(extracted, outputPath)
+		entries = append(entries, file.Name)
 	}
-	return nil
+	if err := checkExtracted(archiveFile, extract, entries); err != nil {
+		return nil, err
+	}
+	return extracted, nil
 }
 
 // no gzip, no anything, just put it there!
-func MoveBinaryIntoPlace(archiveFile, extractPath string, service *types.Service) error {
-	var outputPath string
-	if len(service.ArchivePath) > 0 {
-		outputPath = filepath.Join(extractPath, service.ArchivePath)
+func MoveBinaryIntoPlace(archiveFile, extractPath string, extract []*types.ExtractFile) ([]string, error) {
+	outputPath := filepath.Join(extractPath, filepath.Base(archiveFile))
+	if len(extract) > 0 && extract[0].Output != "" {
+		outputPath = filepath.Join(extractPath, extract[0].Output)
+	} else if len(extract) > 0 && extract[0].Path != "" {
+		outputPath = filepath.Join(extractPath, path.Base(extract[0].Path))
 	}
-	if len(service.OutputPath) > 0 {
-		outputPath = filepath.Join(extractPath, service.OutputPath)
+	if err := os.Rename(archiveFile, outputPath); err != nil {
+		return nil, err
 	}
-	os.Rename(archiveFile, outputPath)
 	os.Chmod(outputPath, 0755)
-	return nil
+	return []string{outputPath}, nil
 }
 
-// ExtractTarGzipArchive processes a tarball file and extracts a
-// single file from the service definition.
-func ExtractTarGzipArchive(archiveFile, extractPath string, service *types.Service) error {
-	var outputPath string
-	file, _ := os.Open(archiveFile)
+// ExtractTarGzipArchive processes a tarball file and extracts the
+// files selected by the artifact's extract rules.
+func ExtractTarGzipArchive(archiveFile, extractPath string, extract []*types.ExtractFile) ([]string, error) {
+	var extracted, entries []string
+	file, err := os.Open(archiveFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 	archive, err := gzip.NewReader(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	tarReader := tar.NewReader(archive)
-	if len(service.ArchivePath) > 0 {
-		outputPath = filepath.Join(extractPath, service.ArchivePath)
-	}
-	if len(service.OutputPath) > 0 {
-		outputPath = filepath.Join(extractPath, service.OutputPath)
-	}
 	for {
 		header, err := tarReader.Next()
-		output := outputPath
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if strings.HasSuffix(header.Name, "/") {
 			glog.V(9).Infof("skpping directory %s", header.Name)
 			continue
 		}
-		if strings.HasSuffix(header.Name, service.ArchivePath) {
-			if output == "" {
-				output = filepath.Join(extractPath, path.Base(header.Name))
-			}
-			glog.V(9).Infof("extracting to %q", output)
-			outfile, err := os.Create(output)
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(outfile, tarReader); err != nil {
-				glog.Errorf("Failed to create file: %q", output)
-			}
-			if err != nil {
-				return err
+		output, ok := extractTarget(extract, header.Name, extractPath)
+		if !ok {
+			continue
+		}
+		glog.V(9).Infof("extracting to %q", output)
+		outfile, err := os.Create(output)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(outfile, tarReader); err != nil {
+			glog.Errorf("Failed to create file: %q", output)
+		}
+		outfile.Chmod(fs.FileMode(header.Mode))
+		outfile.Close()
+		extracted = append(extracted, output)
+		entries = append(entries, header.Name)
+	}
+	if err := checkExtracted(archiveFile, extract, entries); err != nil {
+		return nil, err
+	}
+	return extracted, nil
+}
+
+// checkExtracted fails if an archive had nothing to extract, or if an
+// extract rule selected none of its entries, which would otherwise
+// install the service without its binary.
+func checkExtracted(archiveFile string, extract []*types.ExtractFile, entries []string) error {
+	var missing []string
+	for _, rule := range extract {
+		if rule.Path == "" {
+			continue
+		}
+		found := false
+		for _, entry := range entries {
+			if strings.HasSuffix(entry, rule.Path) {
+				found = true
+				break
 			}
-			outfile.Chmod(fs.FileMode(header.Mode))
-			outfile.Close()
 		}
+		if !found {
+			missing = append(missing, rule.Path)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s has no %s", filepath.Base(archiveFile), strings.Join(missing, ", "))
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("nothing to extract from %s", filepath.Base(archiveFile))
 	}
 	return nil
 }
 
+// extractTarget returns where an archive entry gets extracted to, or
+// false if no extract rule selects it. Without any rules, every entry
+// is extracted under its base name.
+func extractTarget(extract []*types.ExtractFile, entry, extractPath string) (string, bool) {
+	if len(extract) == 0 {
+		return filepath.Join(extractPath, path.Base(entry)), true
+	}
+	for _, rule := range extract {
+		if !strings.HasSuffix(entry, rule.Path) {
+			continue
+		}
+		switch {
+		case rule.Output != "":
+			return filepath.Join(extractPath, rule.Output), true
+		case rule.Path != "":
+			return filepath.Join(extractPath, path.Base(rule.Path)), true
+		default:
+			return filepath.Join(extractPath, path.Base(entry)), true
+		}
+	}
+	return "", false
+}
+
 // little chart to reason about error handling here:
 // manifest download cant-read               cant-write
 // yes      yes      continue (if not exist) continue (assume read-only)
@@ -227,10 +332,31 @@ func Run(cliFlags types.CliFlags) error {
 			return fmt.Errorf("failed to update manifest")
 		}
 	}
+	// Overlays are applied after the update, so they never end up in the manifest file
+	if err := manifest.ApplyOverlays(m, manifest.Overlays(cliFlags)); err != nil {
+		return err
+	}
+	if err := manifest.Select(m, cliFlags); err != nil {
+		return err
+	}
+	if err := manifest.CheckRequirements(m); err != nil {
+		return err
+	}
 	if !cliFlags.Download {
 		return nil
 	}
+	installLock, err := lock.Acquire(cliFlags.DownloadPath, cliFlags.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer installLock.Release()
+	inv, err := inventory.Load(cliFlags.DownloadPath)
+	if err != nil {
+		return err
+	}
 	var waitGroup sync.WaitGroup
+	var failed sync.Once
+	var failure error
 
 	for _, element := range m.Box {
 		if element.Skip {
@@ -238,33 +364,33 @@ func Run(cliFlags types.CliFlags) error {
 		}
 		waitGroup.Add(1)
 		go func(element *types.Service) {
+			defer waitGroup.Done()
 			glog.V(8).Infof("triggering async task for %s", element.Name)
-			err := DownloadService(cliFlags, m, element)
+			extracted, err := DownloadService(cliFlags, m, element)
+			if err != nil {
+				err = fmt.Errorf("failed to download %s: %w", element.Name, err)
+			} else if err = inv.Record(element.Name, element.Release, element.Strategy.Commit, extracted); err != nil {
+				err = fmt.Errorf("failed to record installed files of %s: %w", element.Name, err)
+			}
 			if err != nil {
-				glog.Fatalf("failed to download %s: %s", element.Name, err)
+				glog.Error(err)
+				failed.Do(func() { failure = err })
 			}
-			waitGroup.Done()
 		}(element)
 	}
 	waitGroup.Wait()
+	// Returning instead of exiting releases the lock
+	if failure != nil {
+		return failure
+	}
+
+	if err := inv.Save(); err != nil {
+		return fmt.Errorf("error writing inventory: %w", err)
+	}
 
+	// Each service cleaned up the files it downloaded
 	if !cliFlags.Cleanup {
-		glog.Info("Not cleaning up after extraction")
-	} else {
-		files, err := ioutil.ReadDir(cliFlags.DownloadPath)
-		if err != nil {
-			glog.Fatal(err)
-		}
-		for _, file := range files {
-			if utils.IsCleanupFile(file.Name()) {
-				fullpath := filepath.Join(cliFlags.DownloadPath, file.Name())
-				glog.V(9).Infof("Cleaning up %s", fullpath)
-				err = os.Remove(fullpath)
-				if err != nil {
-					glog.Fatal(err)
-				}
-			}
-		}
+		glog.Infof("Not cleaning up after extraction, downloads are kept in %s", StagingRoot(cliFlags.DownloadPath))
 	}
 
 	return nil
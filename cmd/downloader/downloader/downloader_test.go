@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+var archiveEntries = map[string]string{
+	"victoria-metrics-prod": "victoria-metrics",
+	"docs/README.md":        "readme",
+	"bin/vmagent-prod":      "vmagent",
+}
+
+func writeTarGz(t *testing.T, path string) {
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/", Mode: 0755, Typeflag: tar.TypeDir}))
+	for name, content := range archiveEntries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+}
+
+func writeZip(t *testing.T, path string) {
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	defer zw.Close()
+	for name, content := range archiveEntries {
+		w, err := zw.Create(name + ".exe")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+}
+
+func TestExtractTarGzipArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "vmutils.tar.gz")
+	writeTarGz(t, archive)
+
+	extracted, err := ExtractTarGzipArchive(archive, dir, []*types.ExtractFile{{Path: "vmagent-prod", Output: "lp-vmagent"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "lp-vmagent")}, extracted)
+	content, err := os.ReadFile(filepath.Join(dir, "lp-vmagent"))
+	require.NoError(t, err)
+	require.Equal(t, "vmagent", string(content))
+
+	extracted, err = ExtractTarGzipArchive(archive, dir, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		filepath.Join(dir, "victoria-metrics-prod"),
+		filepath.Join(dir, "README.md"),
+		filepath.Join(dir, "vmagent-prod"),
+	}, extracted)
+
+	_, err = ExtractTarGzipArchive(archive, dir, []*types.ExtractFile{{Path: "vmalert-prod"}})
+	require.EqualError(t, err, "vmutils.tar.gz has no vmalert-prod")
+}
+
+func TestExtractZipArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "victoria-metrics.zip")
+	writeZip(t, archive)
+
+	extracted, err := ExtractZipArchive(archive, dir, []*types.ExtractFile{{Path: "victoria-metrics-prod.exe"}, {Path: "vmagent-prod.exe", Output: "lp-vmagent.exe"}})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		filepath.Join(dir, "victoria-metrics-prod.exe"),
+		filepath.Join(dir, "lp-vmagent.exe"),
+	}, extracted)
+
+	_, err = ExtractZipArchive(archive, dir, []*types.ExtractFile{{Path: "victoria-metrics-prod.exe"}, {Path: "vmalert-prod.exe"}})
+	require.EqualError(t, err, "victoria-metrics.zip has no vmalert-prod.exe")
+}
+
+func TestMoveBinaryIntoPlace(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "livepeer-mist-bigquery-uploader-linux-amd64")
+	require.NoError(t, os.WriteFile(binary, []byte("binary"), 0644))
+	extracted, err := MoveBinaryIntoPlace(binary, dir, []*types.ExtractFile{{Output: "livepeer-mist-bigquery-uploader"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "livepeer-mist-bigquery-uploader")}, extracted)
+	info, err := os.Stat(extracted[0])
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/inventory"
+	"github.com/livepeer/catalyst/cmd/downloader/lock"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+// GC prunes the versions staged in the download path, keeping the
+// newest -gc-keep of each service and those staged within -gc-max-age.
+// The installed version of a service is always kept.
+func GC(cliFlags types.CliFlags) error {
+	if cliFlags.GCKeep <= 0 && cliFlags.GCMaxAge <= 0 {
+		return errors.New("nothing to prune by, set -gc-keep or -gc-max-age")
+	}
+	installLock, err := lock.Acquire(cliFlags.DownloadPath, cliFlags.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer installLock.Release()
+	inv, err := inventory.Load(cliFlags.DownloadPath)
+	if err != nil {
+		return err
+	}
+	installed := map[string]bool{}
+	for _, file := range inv.Files {
+		installed[filepath.Join(unsafeNameRegex.ReplaceAllString(file.Service, "_"), versionName(file.Release, file.Commit))] = true
+	}
+
+	root := StagingRoot(cliFlags.DownloadPath)
+	services, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		glog.Infof("nothing staged in %s", cliFlags.DownloadPath)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pruned, freed := 0, int64(0)
+	for _, service := range services {
+		if !service.IsDir() {
+			continue
+		}
+		versions, err := stagedVersions(filepath.Join(root, service.Name()))
+		if err != nil {
+			return err
+		}
+		// Counts the versions kept besides the installed one
+		kept := 0
+		for _, version := range versions {
+			name := filepath.Join(service.Name(), version.Name())
+			age := time.Since(version.ModTime())
+			var reason string
+			switch {
+			case installed[name]:
+				continue
+			case cliFlags.GCKeep > 0 && kept >= cliFlags.GCKeep:
+				reason = fmt.Sprintf("more than %d staged", cliFlags.GCKeep)
+			case cliFlags.GCMaxAge > 0 && age > cliFlags.GCMaxAge:
+				reason = fmt.Sprintf("staged %s ago", age.Truncate(time.Second))
+			default:
+				kept++
+				continue
+			}
+			size, err := dirSize(filepath.Join(root, name))
+			if err != nil {
+				return err
+			}
+			glog.Infof("removing %s %s, %s", service.Name(), version.Name(), reason)
+			if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+				return err
+			}
+			pruned++
+			freed += size
+		}
+		// Only removes empty directories
+		os.Remove(filepath.Join(root, service.Name()))
+	}
+	glog.Infof("pruned %d staged versions, freed %.1f MiB", pruned, float64(freed)/(1<<20))
+	return nil
+}
+
+// stagedVersions lists the staged versions of a service, newest first.
+func stagedVersions(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []fs.FileInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, info)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].ModTime().After(versions[j].ModTime())
+	})
+	return versions, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil {
+			size += info.Size()
+		}
+		return err
+	})
+	return size, err
+}
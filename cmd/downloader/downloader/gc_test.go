@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/inventory"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionName(t *testing.T) {
+	require.Equal(t, "v0.12.1-4f2a1c0d9e8b", versionName("v0.12.1", "4f2a1c0d9e8b7a6f"))
+	require.Equal(t, "4f2a1c0d", versionName("", "4f2a1c0d"))
+	require.Equal(t, "feat_foo", versionName("feat/foo", ""))
+	require.Equal(t, "unversioned", versionName("", ""))
+}
+
+func TestStagingCleanup(t *testing.T) {
+	dir := t.TempDir()
+	stage, err := newStaging(dir, "mistserver", "catalyst", "4f2a1c0d")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, ".catalyst-staging", "mistserver", "catalyst-4f2a1c0d"), stage.dir)
+
+	archive := filepath.Join(stage.dir, "mistserver.tar.gz")
+	require.NoError(t, os.WriteFile(archive, []byte("archive"), 0644))
+	stage.created = append(stage.created, archive, archive+".TEMP")
+	kept := filepath.Join(stage.dir, "mistserver.tar.gz.sig")
+	require.NoError(t, os.WriteFile(kept, []byte("downloaded by an earlier run"), 0644))
+
+	require.NoError(t, stage.cleanup())
+	require.NoFileExists(t, archive)
+	require.FileExists(t, kept)
+
+	require.NoError(t, os.Remove(kept))
+	require.NoError(t, stage.cleanup())
+	require.NoDirExists(t, filepath.Join(dir, ".catalyst-staging", "mistserver"))
+	require.DirExists(t, filepath.Join(dir, ".catalyst-staging"))
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	stage := func(service, version string, age time.Duration) string {
+		path := filepath.Join(StagingRoot(dir), service, version)
+		require.NoError(t, os.MkdirAll(path, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(path, service+".tar.gz"), []byte("archive"), 0644))
+		modified := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(path, modified, modified))
+		return path
+	}
+	installed := stage("mistserver", "v3-aaaaaaaa", 72*time.Hour)
+	newest := stage("mistserver", "v5-cccccccc", time.Hour)
+	older := stage("mistserver", "v4-bbbbbbbb", 24*time.Hour)
+	other := stage("livepeer", "v1-dddddddd", 48*time.Hour)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MistController"), []byte("binary"), 0755))
+	inv, err := inventory.Load(dir)
+	require.NoError(t, err)
+	require.NoError(t, inv.Record("mistserver", "v3", "aaaaaaaa", []string{filepath.Join(dir, "MistController")}))
+	require.NoError(t, inv.Save())
+
+	flags := types.CliFlags{DownloadPath: dir, GCKeep: 1}
+	require.NoError(t, GC(flags))
+	require.DirExists(t, installed, "the installed version is kept")
+	require.DirExists(t, newest)
+	require.NoDirExists(t, older)
+	require.DirExists(t, other)
+
+	flags.GCKeep, flags.GCMaxAge = 0, 24*time.Hour
+	require.NoError(t, GC(flags))
+	require.DirExists(t, installed)
+	require.DirExists(t, newest)
+	require.NoDirExists(t, other)
+	require.NoDirExists(t, filepath.Dir(other))
+	require.FileExists(t, filepath.Join(dir, "MistController"))
+
+	flags.GCMaxAge = 0
+	require.EqualError(t, GC(flags), "nothing to prune by, set -gc-keep or -gc-max-age")
+}
+
+func TestGCKeepsNewestInstalledVersion(t *testing.T) {
+	dir := t.TempDir()
+	for version, age := range map[string]time.Duration{"v3-aaaaaaaa": time.Hour, "v2-bbbbbbbb": 24 * time.Hour, "v1-cccccccc": 48 * time.Hour} {
+		path := filepath.Join(StagingRoot(dir), "mistserver", version)
+		require.NoError(t, os.MkdirAll(path, 0700))
+		modified := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(path, modified, modified))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MistController"), []byte("binary"), 0755))
+	inv, err := inventory.Load(dir)
+	require.NoError(t, err)
+	require.NoError(t, inv.Record("mistserver", "v3", "aaaaaaaa", []string{filepath.Join(dir, "MistController")}))
+	require.NoError(t, inv.Save())
+
+	require.NoError(t, GC(types.CliFlags{DownloadPath: dir, GCKeep: 1}))
+	require.DirExists(t, filepath.Join(StagingRoot(dir), "mistserver", "v3-aaaaaaaa"))
+	require.DirExists(t, filepath.Join(StagingRoot(dir), "mistserver", "v2-bbbbbbbb"), "kept besides the installed version")
+	require.NoDirExists(t, filepath.Join(StagingRoot(dir), "mistserver", "v1-cccccccc"))
+}
@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
+	glog "github.com/magicsong/color-glog"
+)
+
+var unsafeNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// staging is the private directory that the archive, signature and
+// checksums of one version of a service are downloaded to, at
+// -path/.catalyst-staging/<service>/<version>. It remembers the files
+// it created, so that cleaning up never touches anything else.
+type staging struct {
+	dir     string
+	created []string
+}
+
+// StagingRoot returns the directory that downloads are staged in.
+func StagingRoot(downloadPath string) string {
+	return filepath.Join(downloadPath, constants.StagingDirName)
+}
+
+// versionName names the staging directory of a release and commit.
+func versionName(release, commit string) string {
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	name := release
+	switch {
+	case name == "" && commit == "":
+		name = "unversioned"
+	case name == "":
+		name = commit
+	case commit != "":
+		name += "-" + commit
+	}
+	return unsafeNameRegex.ReplaceAllString(name, "_")
+}
+
+func newStaging(downloadPath, service, release, commit string) (*staging, error) {
+	dir := filepath.Join(StagingRoot(downloadPath), unsafeNameRegex.ReplaceAllString(service, "_"), versionName(release, commit))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// Ages the version for gc, even when every file is reused
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		return nil, err
+	}
+	return &staging{dir: dir}, nil
+}
+
+// download fetches a file into the staging directory, or reuses the one
+// there with skipDownloaded.
+func (s *staging) download(fileName, url string, skipDownloaded bool) (string, error) {
+	path := filepath.Join(s.dir, fileName)
+	if !skipDownloaded || !utils.IsFileExists(path) {
+		s.created = append(s.created, path, path+".TEMP")
+	}
+	return path, utils.DownloadFile(path, url, skipDownloaded)
+}
+
+// cleanup removes the files this run created, and the staging
+// directory once nothing else is left in it.
+func (s *staging) cleanup() error {
+	for _, path := range s.created {
+		glog.V(9).Infof("Cleaning up %s", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	s.created = nil
+	for _, dir := range []string{s.dir, filepath.Dir(s.dir)} {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("can't remove staging directory: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/inventory"
+	"github.com/livepeer/catalyst/cmd/downloader/lock"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+// ErrVerificationFailed is returned by Verify when the installed files
+// no longer match what the downloader extracted.
+var ErrVerificationFailed = errors.New("installed files do not match the inventory")
+
+// Verify re-hashes every file extracted into the download path against
+// the inventory recorded at install time and prints the files that
+// were modified, went missing or appeared since.
+func Verify(cliFlags types.CliFlags) error {
+	if _, err := os.Stat(inventory.Path(cliFlags.DownloadPath)); err != nil {
+		return fmt.Errorf("no inventory found in %s, was anything installed there? %w", cliFlags.DownloadPath, err)
+	}
+	// Not checking an install halfway through
+	installLock, err := lock.Acquire(cliFlags.DownloadPath, cliFlags.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer installLock.Release()
+	inv, err := inventory.Load(cliFlags.DownloadPath)
+	if err != nil {
+		return err
+	}
+	if cliFlags.VerifyRehash {
+		glog.Infof("re-recording hashes of %d installed files in %s", len(inv.Files), cliFlags.DownloadPath)
+		if err := inv.Rehash(); err != nil {
+			return err
+		}
+		return inv.Save()
+	}
+	// Often installed next to the files it verifies
+	ignore := runningBinary(cliFlags.DownloadPath)
+	for _, patterns := range cliFlags.VerifyIgnore {
+		ignore = append(ignore, strings.Split(patterns, ",")...)
+	}
+	report, err := inv.Verify(ignore)
+	if err != nil {
+		return err
+	}
+	if !report.Clean() {
+		fmt.Println(report)
+		return ErrVerificationFailed
+	}
+	glog.Infof("all %d installed files in %s match the inventory", len(inv.Files), cliFlags.DownloadPath)
+	return nil
+}
+
+// runningBinary returns the path of the running executable relative to
+// the download path, if it's in there.
+func runningBinary(dir string) []string {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	rel, err := filepath.Rel(dir, executable)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	return []string{filepath.ToSlash(rel)}
+}
@@ -0,0 +1,18 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunningBinary(t *testing.T) {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	executable, err = filepath.EvalSymlinks(executable)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Base(executable)}, runningBinary(filepath.Dir(executable)))
+	require.Nil(t, runningBinary(t.TempDir()))
+}
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+)
+
+// GetRelease fetches the release of a project with the given tag,
+// including its assets.
+func GetRelease(project, tag string) (*types.TagInformation, error) {
+	return DefaultClient.Release(project, tag)
+}
+
+// AssetMetadata returns the asset of a release with the given file
+// name, whose size and digest github publishes.
+func AssetMetadata(project, tag, fileName string) (*types.ReleaseAsset, error) {
+	return assetMetadata(DefaultClient, project, tag, fileName)
+}
+
+func assetMetadata(client *Client, project, tag, fileName string) (*types.ReleaseAsset, error) {
+	release, err := client.Release(project, tag)
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == fileName {
+			return asset, nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no asset %s", release.TagName, fileName)
+}
+
+// MatchAssets picks the asset of a release to install on each of the
+// platforms. pattern is a glob of asset names in which the variables of
+// the service are expanded, e.g. `vmutils-${platform}-${arch}-*.${ext}`.
+// Every platform needs exactly one matching asset.
+func MatchAssets(service *types.Service, pattern string, assets []*types.ReleaseAsset, platforms []string) (map[string]string, error) {
+	matched := map[string]string{}
+	var missing []string
+	for _, platArch := range platforms {
+		platform, architecture, _ := strings.Cut(platArch, "-")
+		expanded, err := service.Interpolate(pattern, platform, architecture)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, asset := range assets {
+			ok, err := path.Match(expanded, asset.Name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid asset pattern %q: %w", service.Name, pattern, err)
+			}
+			if ok {
+				names = append(names, asset.Name)
+			}
+		}
+		switch len(names) {
+		case 0:
+			missing = append(missing, platArch)
+		case 1:
+			matched[platArch] = names[0]
+		default:
+			return nil, fmt.Errorf("%s: assets %s all match %q", service.Name, strings.Join(names, ", "), expanded)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s: release %s has no asset matching %q for %s", service.Name, service.ReleaseTag(), pattern, strings.Join(missing, ", "))
+	}
+	return matched, nil
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/verification"
+	"github.com/stretchr/testify/require"
+)
+
+var testAssets = []*types.ReleaseAsset{
+	{Name: "victoria-metrics-darwin-amd64-v1.80.0.tar.gz"},
+	{Name: "victoria-metrics-linux-amd64-v1.80.0.tar.gz"},
+	{Name: "victoria-metrics-linux-amd64-v1.80.0-enterprise.tar.gz"},
+	{Name: "victoria-metrics-linux-amd64-v1.80.0_checksums.txt"},
+	{Name: "victoria-metrics-windows-amd64-v1.80.0.zip"},
+}
+
+func TestMatchAssets(t *testing.T) {
+	service := &types.Service{Name: "victoria-metrics", Release: "v1.80.0"}
+	files, err := MatchAssets(service, "${name}-${platform}-${arch}-${release}.${ext}", testAssets, []string{"linux-amd64", "windows-amd64"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"linux-amd64":   "victoria-metrics-linux-amd64-v1.80.0.tar.gz",
+		"windows-amd64": "victoria-metrics-windows-amd64-v1.80.0.zip",
+	}, files)
+
+	_, err = MatchAssets(service, "${name}-${platform}-${arch}-*.tar.gz", testAssets, []string{"linux-amd64"})
+	require.EqualError(t, err, "victoria-metrics: assets victoria-metrics-linux-amd64-v1.80.0.tar.gz, victoria-metrics-linux-amd64-v1.80.0-enterprise.tar.gz all match \"victoria-metrics-linux-amd64-*.tar.gz\"")
+
+	_, err = MatchAssets(service, "${name}-${platform}-${arch}-${release}.${ext}", testAssets, []string{"darwin-amd64", "darwin-arm64", "linux-arm64"})
+	require.EqualError(t, err, "victoria-metrics: release v1.80.0 has no asset matching \"${name}-${platform}-${arch}-${release}.${ext}\" for darwin-arm64, linux-arm64")
+}
+
+func TestGetRelease(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/VictoriaMetrics/VictoriaMetrics/releases/tags/v1.80.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v1.80.0", "assets": [{"name": "vmutils-linux-amd64-v1.80.0.tar.gz", "size": 42, "browser_download_url": "https://example.com/vmutils"}]}`))
+	})
+
+	release, err := client.Release("VictoriaMetrics/VictoriaMetrics", "v1.80.0")
+	require.NoError(t, err)
+	require.Equal(t, []*types.ReleaseAsset{{Name: "vmutils-linux-amd64-v1.80.0.tar.gz", Size: 42, BrowserDownloadURL: "https://example.com/vmutils"}}, release.Assets)
+
+	_, err = client.Release("VictoriaMetrics/VictoriaMetrics", "v0.0.1")
+	require.ErrorContains(t, err, "HTTP 404")
+}
+
+func TestAssetMetadataVerifiesArchive(t *testing.T) {
+	archive := []byte("vmutils archive")
+	sum := sha256.Sum256(archive)
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "v1.80.0", "assets": [
+			{"name": "vmutils-linux-amd64-v1.80.0.tar.gz", "size": %d, "digest": "sha256:%s"},
+			{"name": "vmutils-linux-arm64-v1.80.0.tar.gz", "size": 1, "digest": null}
+		]}`, len(archive), hex.EncodeToString(sum[:]))
+	})
+
+	asset, err := assetMetadata(client, "VictoriaMetrics/VictoriaMetrics", "v1.80.0", "vmutils-linux-amd64-v1.80.0.tar.gz")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), asset.Name)
+	require.NoError(t, os.WriteFile(path, archive, 0644))
+	require.NoError(t, verification.VerifySize(path, asset.Size))
+	require.NoError(t, verification.VerifyDigest(path, asset.Digest))
+
+	require.NoError(t, os.WriteFile(path, []byte("tampered archive"), 0644))
+	require.ErrorContains(t, verification.VerifySize(path, asset.Size), "size mismatch")
+	require.ErrorContains(t, verification.VerifyDigest(path, asset.Digest), "digest mismatch")
+
+	asset, err = assetMetadata(client, "VictoriaMetrics/VictoriaMetrics", "v1.80.0", "vmutils-linux-arm64-v1.80.0.tar.gz")
+	require.NoError(t, err)
+	require.Empty(t, asset.Digest)
+
+	_, err = assetMetadata(client, "VictoriaMetrics/VictoriaMetrics", "v1.80.0", "vmutils-darwin-arm64-v1.80.0.tar.gz")
+	require.EqualError(t, err, "release v1.80.0 has no asset vmutils-darwin-arm64-v1.80.0.tar.gz")
+}
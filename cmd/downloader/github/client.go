@@ -0,0 +1,278 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+// Attempts at a request that keeps hitting rate limits
+const maxAttempts = 3
+
+var nextPageRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Client calls the github REST API. It authenticates with a token when
+// it has one, waits for rate limits to reset, revalidates the responses
+// it cached with their ETags, and follows pagination.
+type Client struct {
+	// https://api.github.com, https://<host>/api/v3 for GitHub
+	// Enterprise, or the URL of a local fake
+	APIBase string
+	Token   string
+	// Directory responses are cached in, no caching if empty
+	CacheDir string
+	// Longest wait for a rate limit to reset before giving up
+	MaxWait time.Duration
+	HTTP    *http.Client
+}
+
+// DefaultClient is used by the functions of this package. It reads its
+// token from GITHUB_TOKEN.
+var DefaultClient = NewClient(constants.GitHubAPIBase, os.Getenv("GITHUB_TOKEN"))
+
+// NewClient creates a client of the API at apiBase, which caches its
+// responses in the user's cache directory.
+func NewClient(apiBase, token string) *Client {
+	client := &Client{
+		APIBase: strings.TrimSuffix(apiBase, "/"),
+		Token:   token,
+		MaxWait: time.Minute,
+		HTTP:    network.Client,
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		client.CacheDir = filepath.Join(dir, constants.AppName, "github")
+	}
+	return client
+}
+
+// WebBase returns the root of the web pages and release downloads that
+// go with the API.
+func (c *Client) WebBase() string {
+	switch {
+	case c.APIBase == constants.GitHubAPIBase:
+		return constants.GitHubWebBase
+	case strings.HasSuffix(c.APIBase, "/api/v3"):
+		return strings.TrimSuffix(c.APIBase, "/api/v3")
+	}
+	// Local fakes serve both
+	return c.APIBase
+}
+
+// cachedResponse is a response body kept on disk with the ETag to
+// revalidate it with.
+type cachedResponse struct {
+	ETag string `json:"etag"`
+	Next string `json:"next,omitempty"`
+	Body []byte `json:"body"`
+}
+
+// Get decodes the JSON at an API path, or at an absolute URL like the
+// ones of pagination links, into out. It returns the URL of the next
+// page, if there is one.
+func (c *Client) Get(path string, out interface{}) (string, error) {
+	url := path
+	if strings.HasPrefix(path, "/") {
+		url = c.APIBase + path
+	}
+	cached := c.readCache(url)
+	for attempt := 1; ; attempt++ {
+		glog.V(9).Infof("Fetching %s", url)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+		if cached != nil {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return "", err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+
+		if wait, limited := rateLimitWait(resp, time.Now()); limited {
+			if wait > c.MaxWait || attempt == maxAttempts {
+				hint := ""
+				if c.Token == "" {
+					hint = ", set GITHUB_TOKEN for a higher limit"
+				}
+				return "", fmt.Errorf("github API rate limit exceeded while fetching %s, it resets in %s%s", url, wait.Round(time.Second), hint)
+			}
+			glog.Warningf("github API rate limit exceeded, retrying in %s", wait.Round(time.Second))
+			time.Sleep(wait)
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached != nil:
+			glog.V(9).Infof("%s hasn't changed, using the cached response", url)
+			return cached.Next, json.Unmarshal(cached.Body, out)
+		case resp.StatusCode != http.StatusOK:
+			var apiError struct {
+				Message string `json:"message"`
+			}
+			json.Unmarshal(body, &apiError)
+			return "", fmt.Errorf("HTTP %d while fetching %s: %s", resp.StatusCode, url, apiError.Message)
+		}
+		if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+			glog.V(9).Infof("%s github API requests left", remaining)
+		}
+		next := ""
+		if match := nextPageRegex.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			next = match[1]
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return "", fmt.Errorf("invalid response from %s: %w", url, err)
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			c.writeCache(url, &cachedResponse{ETag: etag, Next: next, Body: body})
+		}
+		return next, nil
+	}
+}
+
+// rateLimitWait reports whether a response is a rate limit error, and
+// how long until the limit resets.
+func rateLimitWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	// Secondary rate limits
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	wait := time.Unix(reset, 0).Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+func (c *Client) cacheFile(url string) string {
+	// Responses to authenticated requests may hold private data
+	key := sha256.Sum256([]byte(url + "\x00" + c.Token))
+	return filepath.Join(c.CacheDir, hex.EncodeToString(key[:])+".json")
+}
+
+func (c *Client) readCache(url string) *cachedResponse {
+	if c.CacheDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.cacheFile(url))
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil || cached.ETag == "" {
+		return nil
+	}
+	return &cached
+}
+
+func (c *Client) writeCache(url string, response *cachedResponse) {
+	if c.CacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(response)
+	if err == nil {
+		err = os.MkdirAll(c.CacheDir, 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(c.cacheFile(url), data, 0600)
+	}
+	if err != nil {
+		glog.V(5).Infof("not caching github response: %s", err)
+	}
+}
+
+// CommitSHA returns the commit a tag points to, dereferencing
+// annotated tags, whose refs point to a tag object instead.
+func (c *Client) CommitSHA(project, tag string) (string, error) {
+	var ref types.GitRefInfo
+	if _, err := c.Get(fmt.Sprintf(constants.GitHubTagRefPathFormat, project, tag), &ref); err != nil {
+		return "", err
+	}
+	// Tags can point to other tags
+	for depth := 0; ref.Object.Type == "tag" && depth < 5; depth++ {
+		if _, err := c.Get(fmt.Sprintf(constants.GitHubTagObjectPathFormat, project, ref.Object.SHA), &ref); err != nil {
+			return "", err
+		}
+	}
+	if ref.Object.Type != "commit" || ref.Object.SHA == "" {
+		return "", fmt.Errorf("tag %s of %s doesn't point to a commit", tag, project)
+	}
+	return ref.Object.SHA, nil
+}
+
+// LatestRelease returns the newest release of a project that isn't a
+// draft or pre-release.
+func (c *Client) LatestRelease(project string) (*types.TagInformation, error) {
+	var release types.TagInformation
+	if _, err := c.Get(fmt.Sprintf(constants.GitHubLatestReleasePathFormat, project), &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// ListReleases returns every release of a project.
+func (c *Client) ListReleases(project string) ([]*types.TagInformation, error) {
+	var releases []*types.TagInformation
+	next := fmt.Sprintf(constants.GitHubReleasesPathFormat, project)
+	for next != "" {
+		var page []*types.TagInformation
+		var err error
+		if next, err = c.Get(next, &page); err != nil {
+			return nil, err
+		}
+		releases = append(releases, page...)
+	}
+	return releases, nil
+}
+
+// Release returns the release of a project with the given tag,
+// including its assets.
+func (c *Client) Release(project, tag string) (*types.TagInformation, error) {
+	var release types.TagInformation
+	if _, err := c.Get(fmt.Sprintf(constants.GitHubReleaseByTagPathFormat, project, tag), &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// CompareCommits lists the commits after base up to head, oldest first.
+func (c *Client) CompareCommits(project, base, head string) (*types.CompareInformation, error) {
+	var comparison types.CompareInformation
+	if _, err := c.Get(fmt.Sprintf(constants.GitHubCompareAPIPathFormat, project, base, head), &comparison); err != nil {
+		return nil, err
+	}
+	return &comparison, nil
+}
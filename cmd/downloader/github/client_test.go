@@ -0,0 +1,118 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitHub serves the github API from handler, and returns a client
+// of it that doesn't cache.
+func fakeGitHub(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, "")
+	client.CacheDir = ""
+	return client, server
+}
+
+func TestClientSendsToken(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer s3cr3t", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"tag_name": "v0.19.0"}`))
+	})
+	client.Token = "s3cr3t"
+	release, err := client.LatestRelease("livepeer/studio")
+	require.NoError(t, err)
+	require.Equal(t, "v0.19.0", release.TagName)
+}
+
+func TestClientWaitsForRateLimit(t *testing.T) {
+	requests := 0
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v0.19.0"}`))
+	})
+	_, err := client.LatestRelease("livepeer/studio")
+	require.NoError(t, err)
+	require.Equal(t, 2, requests)
+}
+
+func TestClientGivesUpOnLongRateLimits(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+	})
+	_, err := client.LatestRelease("livepeer/studio")
+	require.ErrorContains(t, err, "github API rate limit exceeded")
+	require.ErrorContains(t, err, "set GITHUB_TOKEN")
+}
+
+func TestClientRevalidatesCache(t *testing.T) {
+	requests := 0
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"tag_name": "v0.19.0"}`))
+	})
+	client.CacheDir = t.TempDir()
+	for i := 0; i < 2; i++ {
+		release, err := client.LatestRelease("livepeer/studio")
+		require.NoError(t, err)
+		require.Equal(t, "v0.19.0", release.TagName)
+	}
+	require.Equal(t, 2, requests)
+
+	// Other tokens don't share the cache
+	client.Token = "s3cr3t"
+	require.Nil(t, client.readCache(client.APIBase+"/repos/livepeer/studio/releases/latest"))
+}
+
+func TestCommitSHADereferencesAnnotatedTags(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/livepeer/go-livepeer/git/ref/tags/v0.5.33":
+			fmt.Fprint(w, `{"ref": "refs/tags/v0.5.33", "object": {"sha": "7a1e5a0", "type": "tag"}}`)
+		case "/repos/livepeer/go-livepeer/git/tags/7a1e5a0":
+			fmt.Fprint(w, `{"tag": "v0.5.33", "object": {"sha": "0b3c88f814dccca70a23022f4366eb8069955955", "type": "commit"}}`)
+		case "/repos/livepeer/go-livepeer/git/ref/tags/v0.5.34":
+			fmt.Fprint(w, `{"ref": "refs/tags/v0.5.34", "object": {"sha": "1d0030ed5ef0c75e2652371aab29a5cc453e5518", "type": "commit"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	})
+	sha, err := client.CommitSHA("livepeer/go-livepeer", "v0.5.33")
+	require.NoError(t, err)
+	require.Equal(t, "0b3c88f814dccca70a23022f4366eb8069955955", sha)
+
+	sha, err = client.CommitSHA("livepeer/go-livepeer", "v0.5.34")
+	require.NoError(t, err)
+	require.Equal(t, "1d0030ed5ef0c75e2652371aab29a5cc453e5518", sha)
+
+	_, err = client.CommitSHA("livepeer/go-livepeer", "v9.9.9")
+	require.ErrorContains(t, err, "HTTP 404")
+}
+
+func TestWebBase(t *testing.T) {
+	require.Equal(t, "https://github.com", NewClient("https://api.github.com/", "").WebBase())
+	require.Equal(t, "https://git.example.com", NewClient("https://git.example.com/api/v3", "").WebBase())
+	require.Equal(t, "http://127.0.0.1:8080", NewClient("http://127.0.0.1:8080", "").WebBase())
+}
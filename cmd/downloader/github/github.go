@@ -1,89 +1,98 @@
 package github
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"strings"
 
 	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/semver"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
 	"github.com/livepeer/catalyst/cmd/downloader/utils"
 	glog "github.com/magicsong/color-glog"
 )
 
 // GetCommitSHA uses github api to find SHA for the tagged release
-func GetCommitSHA(project, tag string) *types.GitRefInfo {
-	var refInfo types.GitRefInfo
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/git/ref/tags/%s", project, tag)
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		glog.Fatal(err)
-	}
-	defer resp.Body.Close()
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		glog.Fatal(err)
-	}
-	if err := json.Unmarshal(content, &refInfo); err != nil {
-		glog.Fatal(err)
-	}
-	return &refInfo
+func GetCommitSHA(project, tag string) (string, error) {
+	return DefaultClient.CommitSHA(project, tag)
 }
 
 // GetLatestRelease uses github API to identify information about
 // latest tag for a project.
 func GetLatestRelease(project string) (*types.TagInformation, error) {
 	glog.Infof("Fetching tag information for %s", project)
-	var tagInfo types.TagInformation
-	var apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", project)
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		glog.Error(err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		glog.Error(err)
-		return nil, err
-	}
-	if err := json.Unmarshal(content, &tagInfo); err != nil {
-		glog.Error(err)
-		return nil, err
-	}
-	glog.V(9).Infof("github api response=%d", resp.StatusCode)
-	return &tagInfo, nil
+	return DefaultClient.LatestRelease(project)
 }
 
 // GetArtifactVersion fetches correct version for artifact from
 // github.
-func GetArtifactVersion(release, project string) (string, string) {
+func GetArtifactVersion(release, project string) (string, string, error) {
 	if release == constants.LatestTagReleaseName {
 		tagInfo, err := GetLatestRelease(project)
 		if err != nil {
-			panic(err)
+			return "", "", err
 		}
 		release = tagInfo.TagName
 		glog.V(9).Infof("project=%s, version/tag=%q", project, release)
 	}
-	refInfo := GetCommitSHA(project, release)
-	return release, refInfo.Object.SHA
+	commit, err := GetCommitSHA(project, release)
+	return release, commit, err
+}
+
+// DownloadBase is the root of the release downloads for services that
+// don't set their own `baseURL`. The web root of DefaultClient if empty.
+var DownloadBase string
+
+// BaseURL returns the root of the release downloads the artifacts of a
+// service are fetched from.
+func BaseURL(service *types.Service) string {
+	switch {
+	case service.Strategy.BaseURL != "":
+		return strings.TrimSuffix(service.Strategy.BaseURL, "/")
+	case DownloadBase != "":
+		return strings.TrimSuffix(DownloadBase, "/")
+	}
+	return DefaultClient.WebBase()
 }
 
 // GenerateArtifactURL wraps a `fmt.Sprintf` to template
-func GenerateArtifactURL(project, version, fileName string) string {
-	return fmt.Sprintf(constants.TaggedDownloadURLFormat, project, version, fileName)
+func GenerateArtifactURL(base, project, version, fileName string) string {
+	return base + fmt.Sprintf(constants.TaggedDownloadPathFormat, project, version, fileName)
+}
+
+// WebURL links to a page of the github web UI that goes with the API.
+func WebURL(pathFormat string, args ...interface{}) string {
+	return DefaultClient.WebBase() + fmt.Sprintf(pathFormat, args...)
 }
 
 // GetArtifactInfo generates a structure of all necessary information
 // from using the Github API
-func GetArtifactInfo(platform, architecture, release string, service *types.Service) *types.ArtifactInfo {
+func GetArtifactInfo(platform, architecture, release string, service *types.Service) (*types.ArtifactInfo, error) {
 	project := service.Strategy.Project
 	if len(service.Release) > 0 {
 		release = service.Release
 	}
-	version, commit := GetArtifactVersion(release, project)
+	if service.PinnedRelease != "" {
+		release = service.PinnedRelease
+	} else if semver.IsRange(release) || (release == constants.LatestTagReleaseName && service.AllowPrerelease) {
+		tag, err := ResolveRelease(project, release, service.AllowPrerelease)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", service.Name, err)
+		}
+		service.PinnedRelease = tag
+		release = tag
+	}
+	// With a pinned commit the release is all the URLs need
+	version, commit := release, service.Strategy.Commit
+	if commit == "" || release == constants.LatestTagReleaseName {
+		var err error
+		version, commit, err = GetArtifactVersion(release, project)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", service.Name, err)
+		}
+		if pinned := service.Strategy.Commit; pinned != "" && !utils.SameCommit(pinned, commit) {
+			return nil, fmt.Errorf("%s: the manifest pins commit %s, but release %s is commit %s", service.Name, pinned, version, commit)
+		}
+	}
 	service.Strategy.Commit = commit
 	var info = &types.ArtifactInfo{
 		Name:         service.Name,
@@ -91,32 +100,44 @@ func GetArtifactInfo(platform, architecture, release string, service *types.Serv
 		Architecture: architecture,
 		Version:      version,
 	}
-	extension := utils.PlatformExt(platform)
+	artifact, err := service.ArtifactFor(platform, architecture)
+	if err != nil {
+		return nil, err
+	}
 	packageName := fmt.Sprintf("livepeer-%s", service.Name)
 	if len(service.Binary) > 0 {
 		packageName = service.Binary
 	}
-	info.ArchiveFileName = fmt.Sprintf("%s-%s-%s.%s", packageName, info.Platform, info.Architecture, extension)
-	if service.SrcFilenames != nil {
+	if service.SrcFilenames != nil || service.SrcFilename != "" || service.Artifacts != nil || service.Artifact != nil {
 		packageName = service.Name
-		platArch := fmt.Sprintf("%s-%s", platform, architecture)
-		name, ok := service.SrcFilenames[platArch]
-		if !ok {
-			panic(fmt.Errorf("%s build not found in srcFilenames for %s", service.Name, platArch))
-		}
-		info.ArchiveFileName = name
 	}
 	info.Binary = packageName
-	info.ArchiveURL = GenerateArtifactURL(project, info.Version, info.ArchiveFileName)
+	info.ArchiveFileName = artifact.File
+	info.Digest = artifact.Digest
+	info.Extract = artifact.Extract
+	info.ArchiveURL = GenerateArtifactURL(BaseURL(service), project, info.Version, info.ArchiveFileName)
+
+	if service.SkipChecksum && info.Digest == "" {
+		// Without a checksum file, check the archive against what github publishes about it
+		// Not installing it unchecked just because the API is unreachable
+		asset, err := AssetMetadata(project, info.Version, info.ArchiveFileName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: can't look up the digest of %s: %w", service.Name, info.ArchiveFileName, err)
+		}
+		if asset.Digest == "" {
+			glog.Warningf("github publishes no digest of %s, only checking its size", info.ArchiveFileName)
+		}
+		info.Digest, info.Size = asset.Digest, asset.Size
+	}
 
 	if !service.SkipChecksum {
 		info.ChecksumFileName = fmt.Sprintf("%s_%s", info.Version, constants.ChecksumFileSuffix)
-		info.ChecksumURL = GenerateArtifactURL(project, info.Version, info.ChecksumFileName)
+		info.ChecksumURL = GenerateArtifactURL(BaseURL(service), project, info.Version, info.ChecksumFileName)
 	}
 
 	if !service.SkipGPG {
 		info.SignatureFileName = fmt.Sprintf("%s.%s", info.ArchiveFileName, constants.SignatureFileExtension)
-		info.SignatureURL = GenerateArtifactURL(project, info.Version, info.SignatureFileName)
+		info.SignatureURL = GenerateArtifactURL(BaseURL(service), project, info.Version, info.SignatureFileName)
 	}
-	return info
+	return info, nil
 }
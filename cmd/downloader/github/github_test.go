@@ -1,53 +1,173 @@
 package github
 
 import (
-	"runtime"
+	"net/http"
+	"os"
 	"testing"
 
 	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps the tests off the real API, those that don't use a
+// fake fail instead.
+func TestMain(m *testing.M) {
+	DefaultClient = NewClient("https://api.github.invalid", "")
+	DefaultClient.CacheDir = ""
+	os.Exit(m.Run())
+}
+
+// useFixtures points the package functions at a fake of the API that
+// serves the responses recorded in testdata.
+func useFixtures(t *testing.T) *Client {
+	client, _ := fakeGitHub(t, http.FileServer(http.Dir("testdata")).ServeHTTP)
+	useClient(t, client)
+	return client
+}
+
+func useClient(t *testing.T, client *Client) {
+	previous := DefaultClient
+	DefaultClient = client
+	t.Cleanup(func() { DefaultClient = previous })
+}
+
 func TestCommitSHA(t *testing.T) {
+	useFixtures(t)
 	projects := map[string][]string{
 		"livepeer/go-livepeer":   {"v0.5.33", "0b3c88f814dccca70a23022f4366eb8069955955"},
 		"livepeer/livepeer-data": {"v0.4.17", "4f6696fb83d15bb738d4ff824d47ad032d8d96f9"},
 	}
 	for project, tag := range projects {
-		refInfo := GetCommitSHA(project, tag[0])
-		if refInfo.Object.SHA != tag[1] {
-			t.Errorf("invalid commit SHA for project=%s tag=%s", project, tag)
-			t.Fail()
-		}
+		sha, err := GetCommitSHA(project, tag[0])
+		require.NoError(t, err)
+		require.Equal(t, tag[1], sha, "commit of %s %s", project, tag[0])
 	}
 }
 
 func TestTagInformation(t *testing.T) {
-	projects := []string{
-		"livepeer/stream-tester",
-		"livepeer/livepeer-data",
-		"livepeer/transcode-cli",
-		"livepeer/livepeer-com",
-		"livepeer/go-livepeer",
-	}
-	for _, project := range projects {
-		_, err := GetLatestRelease(project)
-		if err != nil {
-			t.Error("could not fetch tag information")
-			t.Fail()
-		}
+	useFixtures(t)
+	projects := map[string]string{
+		"livepeer/livepeer-com": "v0.19.0",
+		"livepeer/go-livepeer":  "v0.5.35",
+	}
+	for project, tag := range projects {
+		release, err := GetLatestRelease(project)
+		require.NoError(t, err)
+		require.Equal(t, tag, release.TagName)
 	}
+	_, err := GetLatestRelease("livepeer/unknown")
+	require.Error(t, err)
 }
 
 func TestArtifactInfo(t *testing.T) {
+	client := useFixtures(t)
+	serviceInfo := &types.Service{
+		Name: "api",
+		Strategy: &types.DownloadStrategy{
+			Project: "livepeer/livepeer-com",
+		},
+	}
+	info, err := GetArtifactInfo("linux", "amd64", "latest", serviceInfo)
+	require.NoError(t, err)
+	require.Equal(t, "livepeer-api", info.Binary)
+	require.Equal(t, "v0.19.0", info.Version)
+	require.Equal(t, "a4b62c9e1f0d5b7a3c8e2d6f9b0a1c4e7d3f5b28", serviceInfo.Strategy.Commit)
+	require.Equal(t, client.APIBase+"/livepeer/livepeer-com/releases/download/v0.19.0/livepeer-api-linux-amd64.tar.gz", info.ArchiveURL)
+}
+
+func TestArtifactInfoBaseURL(t *testing.T) {
+	useFixtures(t)
 	serviceInfo := &types.Service{
 		Name: "api",
 		Strategy: &types.DownloadStrategy{
 			Project: "livepeer/livepeer-com",
+			BaseURL: "https://mirror.example.com/github/",
 		},
 	}
-	info := GetArtifactInfo(runtime.GOOS, runtime.GOARCH, "latest", serviceInfo)
-	if info.Binary != "livepeer-api" {
-		t.Error("The binary name generated for service doesn't match")
-		t.Fail()
+	info, err := GetArtifactInfo("linux", "amd64", "latest", serviceInfo)
+	require.NoError(t, err)
+	require.Equal(t, "https://mirror.example.com/github/livepeer/livepeer-com/releases/download/v0.19.0/livepeer-api-linux-amd64.tar.gz", info.ArchiveURL)
+	require.Equal(t, "https://mirror.example.com/github/livepeer/livepeer-com/releases/download/v0.19.0/v0.19.0_checksums.txt", info.ChecksumURL)
+
+	defer func() { DownloadBase = "" }()
+	DownloadBase = "https://downloads.example.com"
+	serviceInfo.Strategy.BaseURL = ""
+	info, err = GetArtifactInfo("linux", "amd64", "latest", serviceInfo)
+	require.NoError(t, err)
+	require.Equal(t, "https://downloads.example.com/livepeer/livepeer-com/releases/download/v0.19.0/livepeer-api-linux-amd64.tar.gz", info.ArchiveURL)
+}
+
+func TestArtifactInfoPinnedCommitIsNotFetched(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request for %s", r.URL.Path)
+	})
+	useClient(t, client)
+	service := &types.Service{
+		Name:    "livepeer",
+		Release: "v0.5.33",
+		Strategy: &types.DownloadStrategy{
+			Project: "livepeer/go-livepeer",
+			Commit:  "0b3c88f814dccca70a23022f4366eb8069955955",
+		},
+	}
+	info, err := GetArtifactInfo("linux", "amd64", "v0.5.33", service)
+	require.NoError(t, err)
+	require.Equal(t, "v0.5.33", info.Version)
+	require.Equal(t, "0b3c88f814dccca70a23022f4366eb8069955955", service.Strategy.Commit)
+}
+
+func TestArtifactInfoPinnedCommitMismatch(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/livepeer/go-livepeer/releases/latest":
+			w.Write([]byte(`{"tag_name": "v0.5.34"}`))
+		case "/repos/livepeer/go-livepeer/git/ref/tags/v0.5.34":
+			w.Write([]byte(`{"object": {"sha": "4f6696fb83d15bb738d4ff824d47ad032d8d96f9", "type": "commit"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	useClient(t, client)
+	service := &types.Service{
+		Name:    "livepeer",
+		Release: "latest",
+		Strategy: &types.DownloadStrategy{
+			Project: "livepeer/go-livepeer",
+			Commit:  "0b3c88f",
+		},
 	}
+	_, err := GetArtifactInfo("linux", "amd64", "latest", service)
+	require.EqualError(t, err, "livepeer: the manifest pins commit 0b3c88f, but release v0.5.34 is commit 4f6696fb83d15bb738d4ff824d47ad032d8d96f9")
+}
+
+func TestArtifactInfoAssetDigest(t *testing.T) {
+	down := false
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if down || r.URL.Path != "/repos/VictoriaMetrics/VictoriaMetrics/releases/tags/v1.80.0" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v1.80.0", "assets": [{"name": "vmutils-linux-amd64-v1.80.0.tar.gz", "size": 42, "digest": "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}]}`))
+	})
+	useClient(t, client)
+	service := &types.Service{
+		Name:         "vmagent",
+		Release:      "v1.80.0",
+		SkipGPG:      true,
+		SkipChecksum: true,
+		SrcFilename:  "vmutils-${platform}-${arch}-${release}.${ext}",
+		Strategy: &types.DownloadStrategy{
+			Project: "VictoriaMetrics/VictoriaMetrics",
+			Commit:  "c3f84810116f096e47100c57af88228a14433b91",
+		},
+	}
+	info, err := GetArtifactInfo("linux", "amd64", "v1.80.0", service)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", info.Digest)
+	require.Equal(t, int64(42), info.Size)
+
+	// An API hiccup doesn't skip the verification
+	down = true
+	_, err = GetArtifactInfo("linux", "amd64", "v1.80.0", service)
+	require.ErrorContains(t, err, "vmagent: can't look up the digest of vmutils-linux-amd64-v1.80.0.tar.gz")
 }
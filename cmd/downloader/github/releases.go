@@ -0,0 +1,113 @@
+package github
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/semver"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+// ListReleases returns every release of a project, following the
+// pagination of the github API.
+func ListReleases(project string) ([]*types.TagInformation, error) {
+	return DefaultClient.ListReleases(project)
+}
+
+// ResolveRelease picks the newest release of a project in a version
+// range, and warns about newer releases outside of it. A release of
+// "latest" matches every version. Drafts are never picked, and
+// pre-releases only when allowPrerelease is set.
+func ResolveRelease(project, release string, allowPrerelease bool) (string, error) {
+	releases, err := ListReleases(project)
+	if err != nil {
+		return "", err
+	}
+	tag, newer, err := PickRelease(releases, release, allowPrerelease)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", project, err)
+	}
+	for _, version := range newer {
+		glog.Warningf("%s: %s is newer than %s, but outside of %q", project, version, tag, release)
+	}
+	return tag, nil
+}
+
+// PickRelease returns the tag of the newest release in a version range,
+// and the tags of the newer releases outside of it.
+func PickRelease(releases []*types.TagInformation, release string, allowPrerelease bool) (string, []string, error) {
+	var constraint *semver.Constraint
+	if release != constants.LatestTagReleaseName {
+		var err error
+		if constraint, err = semver.ParseConstraint(release); err != nil {
+			return "", nil, err
+		}
+	}
+	var best *semver.Version
+	var candidates []*semver.Version
+	for _, info := range releases {
+		version, err := semver.Parse(info.TagName)
+		if err != nil || info.Draft {
+			continue
+		}
+		if (info.PreRelease || version.Prerelease != "") && !allowPrerelease {
+			continue
+		}
+		candidates = append(candidates, version)
+		if (constraint == nil || constraint.Check(version)) && (best == nil || version.Compare(best) > 0) {
+			best = version
+		}
+	}
+	if best == nil {
+		return "", nil, fmt.Errorf("no release matches %q", release)
+	}
+	var newer []string
+	for _, version := range candidates {
+		if version.Compare(best) > 0 {
+			newer = append(newer, version.String())
+		}
+	}
+	return best.String(), newer, nil
+}
+
+// CompareCommits lists the commits after base up to head, oldest first.
+func CompareCommits(project, base, head string) (*types.CompareInformation, error) {
+	return DefaultClient.CompareCommits(project, base, head)
+}
+
+// ReleasesBetween returns the releases after the from tag up to and
+// including the to tag, newest first.
+func ReleasesBetween(releases []*types.TagInformation, from, to string) []*types.TagInformation {
+	fromVersion, fromErr := semver.Parse(from)
+	toVersion, toErr := semver.Parse(to)
+	if fromErr != nil || toErr != nil {
+		return nil
+	}
+	var between []*types.TagInformation
+	var versions []*semver.Version
+	for _, release := range releases {
+		version, err := semver.Parse(release.TagName)
+		if err != nil || release.Draft || version.Compare(fromVersion) <= 0 || version.Compare(toVersion) > 0 {
+			continue
+		}
+		between = append(between, release)
+		versions = append(versions, version)
+	}
+	sort.Sort(byVersion{between, versions})
+	return between
+}
+
+// byVersion sorts releases newest first.
+type byVersion struct {
+	releases []*types.TagInformation
+	versions []*semver.Version
+}
+
+func (b byVersion) Len() int           { return len(b.releases) }
+func (b byVersion) Less(i, j int) bool { return b.versions[i].Compare(b.versions[j]) > 0 }
+func (b byVersion) Swap(i, j int) {
+	b.releases[i], b.releases[j] = b.releases[j], b.releases[i]
+	b.versions[i], b.versions[j] = b.versions[j], b.versions[i]
+}
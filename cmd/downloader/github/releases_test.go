@@ -0,0 +1,97 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+var testReleases = []*types.TagInformation{
+	{TagName: "v2.0.0-rc.1", PreRelease: true},
+	{TagName: "v1.81.0"},
+	{TagName: "v1.80.1"},
+	{TagName: "v1.80.0"},
+	{TagName: "v1.79.2"},
+	{TagName: "v1.79.1"},
+	{TagName: "v1.79.3", Draft: true},
+	{TagName: "pmm-6401-v1.80.0"},
+}
+
+func TestListReleasesFollowsPagination(t *testing.T) {
+	var server *httptest.Server
+	client, server := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/VictoriaMetrics/VictoriaMetrics/releases", r.URL.Path)
+		page := 0
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		end := page*3 + 3
+		if end < len(testReleases) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next", <%s%s?page=2>; rel="last"`, server.URL, r.URL.Path, page+1, server.URL, r.URL.Path))
+		} else {
+			end = len(testReleases)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(testReleases[page*3:end]))
+	})
+
+	releases, err := client.ListReleases("VictoriaMetrics/VictoriaMetrics")
+	require.NoError(t, err)
+	require.Equal(t, testReleases, releases)
+}
+
+func TestListReleasesError(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+	})
+	_, err := client.ListReleases("livepeer/studio")
+	require.ErrorContains(t, err, "HTTP 403")
+	require.ErrorContains(t, err, "Resource not accessible by integration")
+}
+
+func TestPickRelease(t *testing.T) {
+	for _, tc := range []struct {
+		release         string
+		allowPrerelease bool
+		tag             string
+		newer           []string
+	}{
+		{"~1.79", false, "v1.79.2", []string{"v1.81.0", "v1.80.1", "v1.80.0"}},
+		{">=1.79 <2", false, "v1.81.0", nil},
+		{">=1.79 <2", true, "v1.81.0", []string{"v2.0.0-rc.1"}},
+		{"latest", true, "v2.0.0-rc.1", nil},
+		{"latest", false, "v1.81.0", nil},
+	} {
+		tag, newer, err := PickRelease(testReleases, tc.release, tc.allowPrerelease)
+		require.NoError(t, err, tc.release)
+		require.Equal(t, tc.tag, tag, tc.release)
+		require.Equal(t, tc.newer, newer, tc.release)
+	}
+
+	_, _, err := PickRelease(testReleases, "~1.78", false)
+	require.EqualError(t, err, `no release matches "~1.78"`)
+}
+
+func TestReleasesBetween(t *testing.T) {
+	var tags []string
+	for _, release := range ReleasesBetween(testReleases, "v1.79.1", "v1.80.1") {
+		tags = append(tags, release.TagName)
+	}
+	require.Equal(t, []string{"v1.80.1", "v1.80.0", "v1.79.2"}, tags)
+	require.Empty(t, ReleasesBetween(testReleases, "main", "v1.80.1"))
+}
+
+func TestCompareCommits(t *testing.T) {
+	client, _ := fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/livepeer/catalyst-api/compare/abc...def", r.URL.Path)
+		w.Write([]byte(`{"total_commits": 2, "commits": [{"sha": "abc1234567", "commit": {"message": "Fix it\n\nDetails"}}, {"sha": "def1234567", "commit": {"message": "Add it"}}]}`))
+	})
+	comparison, err := client.CompareCommits("livepeer/catalyst-api", "abc", "def")
+	require.NoError(t, err)
+	require.Equal(t, 2, comparison.TotalCommits)
+	require.Equal(t, "def1234567", comparison.Commits[1].SHA)
+	require.Equal(t, "Fix it\n\nDetails", comparison.Commits[0].Commit.Message)
+}
@@ -0,0 +1,124 @@
+package hooks
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	// Abbreviated commit SHAs are at least this long in `-version` output
+	shortCommitLength = 7
+	// How long to wait for the output of a hook killed after its timeout
+	killWait = time.Second
+)
+
+// Run executes a manifest hook for a service with a timeout in a clean
+// environment whose PATH starts with the download path, so freshly
+// installed binaries can be called by name. It returns an error if the
+// command fails or its output doesn't match what the hook expects.
+func Run(stage string, hook *types.Hook, service *types.Service, downloadPath string) error {
+	if hook == nil {
+		return nil
+	}
+	if len(hook.Command) == 0 {
+		return fmt.Errorf("%s hook of %s has no command", stage, service.Name)
+	}
+	timeout := DefaultTimeout
+	if hook.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(hook.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout for %s hook of %s: %w", stage, service.Name, err)
+		}
+	}
+	dir, err := filepath.Abs(downloadPath)
+	if err != nil {
+		return err
+	}
+	command, err := resolveCommand(hook.Command[0], dir)
+	if err != nil {
+		return fmt.Errorf("%s hook of %s: %w", stage, service.Name, err)
+	}
+
+	cmd := exec.Command(command, hook.Command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + strings.Join([]string{dir, "/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}, string(os.PathListSeparator)),
+		"HOME=" + os.TempDir(),
+		"LANG=C",
+	}
+	var buffer bytes.Buffer
+	cmd.Stdout = &buffer
+	cmd.Stderr = &buffer
+	// So that a timeout kills the children too, which would otherwise
+	// keep the output pipe open
+	setProcessGroup(cmd)
+	glog.V(5).Infof("running %s hook of %s: %v", stage, service.Name, hook.Command)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s hook of %s failed: %w", stage, service.Name, err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		killProcessGroup(cmd)
+		// Children that left the group may still hold the pipe, don't wait on them
+		select {
+		case <-done:
+		case <-time.After(killWait):
+		}
+		return fmt.Errorf("%s hook of %s timed out after %s", stage, service.Name, timeout)
+	}
+	output := buffer.Bytes()
+	glog.V(9).Infof("%s hook output: %s", stage, string(output))
+	if err != nil {
+		return fmt.Errorf("%s hook of %s failed: %w: %s", stage, service.Name, err, strings.TrimSpace(string(output)))
+	}
+
+	if hook.Expect != "" {
+		expect, err := regexp.Compile(hook.Expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect pattern for %s hook of %s: %w", stage, service.Name, err)
+		}
+		if !expect.Match(output) {
+			return fmt.Errorf("%s hook of %s: output doesn't match %q: %s", stage, service.Name, hook.Expect, strings.TrimSpace(string(output)))
+		}
+	}
+	if hook.ExpectCommit {
+		commit := service.Strategy.Commit
+		if len(commit) > shortCommitLength {
+			commit = commit[:shortCommitLength]
+		}
+		if commit == "" || !strings.Contains(string(output), commit) {
+			return fmt.Errorf("%s hook of %s: output doesn't mention commit %q: %s", stage, service.Name, service.Strategy.Commit, strings.TrimSpace(string(output)))
+		}
+	}
+	glog.Infof("%s hook of %s passed", stage, service.Name)
+	return nil
+}
+
+// resolveCommand prefers binaries from the download path over the
+// ones found on the PATH of the downloader itself.
+func resolveCommand(name, dir string) (string, error) {
+	if strings.ContainsRune(name, os.PathSeparator) {
+		if filepath.IsAbs(name) {
+			return name, nil
+		}
+		return filepath.Join(dir, name), nil
+	}
+	if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+		return filepath.Join(dir, name), nil
+	}
+	return exec.LookPath(name)
+}
@@ -0,0 +1,56 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook fixtures are shell scripts")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"Livepeer Node Version: 0.7.2-3acf4eab\"\necho \"PATH=$PATH\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "livepeer"), []byte(script), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "slow"), []byte("#!/bin/sh\nsleep 5\n"), 0755))
+	service := &types.Service{
+		Name:     "livepeer",
+		Strategy: &types.DownloadStrategy{Commit: "3acf4eab323df725f60feae6a8b288030cd58fb5"},
+	}
+
+	require.NoError(t, Run("check", nil, service, dir))
+	require.NoError(t, Run("check", &types.Hook{
+		Command:      []string{"livepeer", "-version"},
+		Expect:       `Node Version: \d+\.\d+\.\d+`,
+		ExpectCommit: true,
+	}, service, dir))
+	require.NoError(t, Run("check", &types.Hook{
+		Command: []string{"livepeer"},
+		Expect:  "PATH=" + dir + ":",
+	}, service, dir))
+
+	require.ErrorContains(t, Run("check", &types.Hook{
+		Command: []string{"livepeer"},
+		Expect:  "MistController",
+	}, service, dir), "doesn't match")
+	service.Strategy.Commit = "0846fae8c0cae4296f49c281b71e1b1052c623c4"
+	require.ErrorContains(t, Run("check", &types.Hook{
+		Command:      []string{"livepeer"},
+		ExpectCommit: true,
+	}, service, dir), "doesn't mention commit")
+	started := time.Now()
+	require.ErrorContains(t, Run("postInstall", &types.Hook{
+		Command: []string{"slow"},
+		Timeout: "100ms",
+	}, service, dir), "timed out")
+	require.Less(t, time.Since(started), 2*time.Second, "the sleep holding the output is killed too")
+	require.ErrorContains(t, Run("preInstall", &types.Hook{
+		Command: []string{"false"},
+	}, service, dir), "failed")
+}
@@ -0,0 +1,11 @@
+package hooks
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills a hook. Its children are left running, as
+// Windows has no process groups to kill at once.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,251 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+)
+
+// File is a single extracted file as it looked right after install.
+type File struct {
+	Service string `json:"service"`
+	Release string `json:"release,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+}
+
+// Inventory records every file the downloader extracted into a
+// download path, keyed by path relative to that directory.
+type Inventory struct {
+	Files map[string]*File `json:"files"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// Report lists the differences between an inventory and the files
+// currently on disk.
+type Report struct {
+	Modified   []string
+	Missing    []string
+	Unexpected []string
+}
+
+// Path returns the location of the inventory file for a download path.
+func Path(dir string) string {
+	return filepath.Join(dir, constants.InventoryFileName)
+}
+
+// Load reads the inventory of a download path. A missing inventory
+// file yields an empty inventory.
+func Load(dir string) (*Inventory, error) {
+	inv := &Inventory{Files: map[string]*File{}, dir: dir}
+	content, err := os.ReadFile(Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return inv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, inv); err != nil {
+		return nil, fmt.Errorf("error parsing inventory %s: %w", Path(dir), err)
+	}
+	if inv.Files == nil {
+		inv.Files = map[string]*File{}
+	}
+	return inv, nil
+}
+
+// Save writes the inventory back into its download path.
+func (inv *Inventory) Save() error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	content, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := fmt.Sprintf("%s.TEMP", Path(inv.dir))
+	if err := os.WriteFile(tempPath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, Path(inv.dir))
+}
+
+// Record replaces the entries of a service with the hashes of the
+// given freshly extracted files.
+func (inv *Inventory) Record(service, release, commit string, paths []string) error {
+	files := map[string]*File{}
+	for _, path := range paths {
+		rel, err := filepath.Rel(inv.dir, path)
+		if err != nil {
+			return err
+		}
+		sum, size, err := HashFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = &File{
+			Service: service,
+			Release: release,
+			Commit:  commit,
+			SHA256:  sum,
+			Size:    size,
+		}
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for name, file := range inv.Files {
+		if file.Service == service {
+			delete(inv.Files, name)
+		}
+	}
+	for name, file := range files {
+		inv.Files[name] = file
+	}
+	return nil
+}
+
+// Rehash updates the recorded hashes of all inventoried files, e.g.
+// after they were intentionally stripped. Nothing is updated if any of
+// them is missing.
+func (inv *Inventory) Rehash() error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	type hash struct {
+		sum  string
+		size int64
+	}
+	hashes := map[string]hash{}
+	var missing []string
+	for name := range inv.Files {
+		sum, size, err := HashFile(filepath.Join(inv.dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		hashes[name] = hash{sum, size}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("can't rehash, installed files are missing: %s", strings.Join(missing, ", "))
+	}
+	for name, file := range inv.Files {
+		file.SHA256 = hashes[name].sum
+		file.Size = hashes[name].size
+	}
+	return nil
+}
+
+// Verify re-hashes every inventoried file and walks the download path
+// looking for files the inventory doesn't know about. Files matching
+// one of the ignore globs are never reported as unexpected.
+func (inv *Inventory) Verify(ignore []string) (*Report, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	report := &Report{}
+	for name, file := range inv.Files {
+		sum, _, err := HashFile(filepath.Join(inv.dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sum != file.SHA256 {
+			report.Modified = append(report.Modified, name)
+		}
+	}
+	err := filepath.WalkDir(inv.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == filepath.Join(inv.dir, constants.StagingDirName) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(inv.dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if _, ok := inv.Files[name]; ok || isIgnored(name, ignore) {
+			return nil
+		}
+		report.Unexpected = append(report.Unexpected, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(report.Modified)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unexpected)
+	return report, nil
+}
+
+// Clean reports whether the installed files match the inventory exactly.
+func (r *Report) Clean() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+func (r *Report) String() string {
+	var lines []string
+	for _, name := range r.Modified {
+		lines = append(lines, fmt.Sprintf("modified:   %s", name))
+	}
+	for _, name := range r.Missing {
+		lines = append(lines, fmt.Sprintf("missing:    %s", name))
+	}
+	for _, name := range r.Unexpected {
+		lines = append(lines, fmt.Sprintf("unexpected: %s", name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HashFile returns the hex encoded sha256 digest and size of a file.
+func HashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+func isIgnored(name string, ignore []string) bool {
+	if name == constants.InventoryFileName || name == constants.ManifestCacheFileName || name == constants.LockFileName {
+		return true
+	}
+	for _, pattern := range ignore {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(name)); matched {
+			return true
+		}
+	}
+	return false
+}
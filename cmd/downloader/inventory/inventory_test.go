@@ -0,0 +1,67 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"livepeer", "livepeer-cli", "MistController"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0755))
+	}
+	inv, err := Load(dir)
+	require.NoError(t, err)
+	require.NoError(t, inv.Record("livepeer", "master", "abc", []string{
+		filepath.Join(dir, "livepeer"),
+		filepath.Join(dir, "livepeer-cli"),
+	}))
+	require.NoError(t, inv.Record("mistserver", "catalyst", "def", []string{
+		filepath.Join(dir, "MistController"),
+	}))
+	require.NoError(t, inv.Save())
+
+	inv, err = Load(dir)
+	require.NoError(t, err)
+	report, err := inv.Verify(nil)
+	require.NoError(t, err)
+	require.True(t, report.Clean(), report.String())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "livepeer"), []byte("hot-patched"), 0755))
+	require.NoError(t, os.Remove(filepath.Join(dir, "livepeer-cli")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MistInEvil"), nil, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "catalyst"), nil, 0755))
+
+	report, err = inv.Verify([]string{"catalyst"})
+	require.NoError(t, err)
+	require.False(t, report.Clean())
+	require.Equal(t, []string{"livepeer"}, report.Modified)
+	require.Equal(t, []string{"livepeer-cli"}, report.Missing)
+	require.Equal(t, []string{"MistInEvil"}, report.Unexpected)
+
+	require.EqualError(t, inv.Rehash(), "can't rehash, installed files are missing: livepeer-cli")
+	report, err = inv.Verify([]string{"catalyst", "MistInEvil"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"livepeer"}, report.Modified, "not rehashed")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "livepeer-cli"), nil, 0755))
+	require.NoError(t, inv.Rehash())
+	report, err = inv.Verify([]string{"catalyst", "MistInEvil"})
+	require.NoError(t, err)
+	require.True(t, report.Clean(), report.String())
+}
+
+func TestRecordReplacesService(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old"), nil, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new"), nil, 0755))
+	inv, err := Load(dir)
+	require.NoError(t, err)
+	require.NoError(t, inv.Record("svc", "v1", "", []string{filepath.Join(dir, "old")}))
+	require.NoError(t, inv.Record("svc", "v2", "", []string{filepath.Join(dir, "new")}))
+	require.Len(t, inv.Files, 1)
+	require.Equal(t, "v2", inv.Files["new"].Release)
+}
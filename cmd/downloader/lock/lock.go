@@ -0,0 +1,238 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	glog "github.com/magicsong/color-glog"
+)
+
+var (
+	// RefreshInterval is how often a holder touches its lock file, to
+	// tell that it's still alive.
+	RefreshInterval = 10 * time.Second
+	// StaleAfter is how long a lock file may go without being touched
+	// before it's considered abandoned, e.g. by a killed container.
+	StaleAfter = time.Minute
+	// How often waiters check whether the lock was released
+	pollInterval = 500 * time.Millisecond
+)
+
+// Holder describes the process holding a lock.
+type Holder struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Command  string    `json:"command"`
+	Since    time.Time `json:"since"`
+}
+
+func (h Holder) same(other Holder) bool {
+	return h.PID == other.PID && h.Hostname == other.Hostname && h.Command == other.Command && h.Since.Equal(other.Since)
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("pid %d on %s (%s), since %s", h.PID, h.Hostname, h.Command, h.Since.Format(time.RFC3339))
+}
+
+// Lock is an advisory lock of a download path, held by the process
+// whose details are in its lock file. Every process installing into
+// the directory takes it, so they don't clobber each other's files.
+type Lock struct {
+	path   string
+	holder Holder
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Path returns the location of the lock file of a download path.
+func Path(dir string) string {
+	return filepath.Join(dir, constants.LockFileName)
+}
+
+// Subcommands such as `verify`, as opposed to flags and URLs
+var subcommandRegex = regexp.MustCompile(`^[a-z][a-z-]*$`)
+
+// command describes a process by its binary and subcommand, leaving out
+// the arguments, which may hold credentials.
+func command(args []string) string {
+	for i, arg := range args {
+		if i > 0 && !subcommandRegex.MatchString(arg) {
+			return strings.Join(args[:i], " ")
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// Acquire takes the lock of a download path, waiting up to timeout for
+// another process to release it. Locks whose holder died are taken over.
+func Acquire(dir string, timeout time.Duration) (*Lock, error) {
+	hostname, _ := os.Hostname()
+	l := &Lock{
+		path: Path(dir),
+		holder: Holder{
+			PID:      os.Getpid(),
+			Hostname: hostname,
+			Command:  command(os.Args),
+			Since:    time.Now().UTC().Truncate(time.Second),
+		},
+	}
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err := l.create()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("can't lock %s: %w", dir, err)
+		}
+		current, stale, err := inspect(l.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Released in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't lock %s: %w", dir, err)
+		}
+		if stale != "" {
+			glog.Warningf("taking over the lock of %s from %s: %s", dir, current, stale)
+			if err := removeIfHeldBy(l.path, current); err != nil {
+				return nil, fmt.Errorf("can't remove stale lock %s: %w", l.path, err)
+			}
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%s is locked by %s, gave up after waiting %s. Remove %s if that process is gone", dir, current, timeout, l.path)
+		}
+		if !waiting {
+			glog.Infof("waiting up to %s for %s, locked by %s", timeout, dir, current)
+			waiting = true
+		}
+		wait := time.Until(deadline)
+		if wait > pollInterval {
+			wait = pollInterval
+		}
+		time.Sleep(wait)
+	}
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	go l.refresh()
+	glog.V(6).Infof("locked %s", dir)
+	return l, nil
+}
+
+// Release stops refreshing the lock and removes its file, unless
+// another process took it over.
+func (l *Lock) Release() error {
+	close(l.stop)
+	<-l.done
+	return removeIfHeldBy(l.path, l.holder)
+}
+
+func (l *Lock) create() error {
+	data, err := json.Marshal(l.holder)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(l.path)
+	}
+	return err
+}
+
+func (l *Lock) refresh() {
+	defer close(l.done)
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(l.path, now, now); err != nil {
+				glog.Errorf("can't refresh lock %s, another process may take it over: %s", l.path, err)
+			}
+		}
+	}
+}
+
+// inspect reads the holder of a lock file, and why the lock is stale
+// if it is.
+func inspect(path string) (Holder, string, error) {
+	var holder Holder
+	info, err := os.Stat(path)
+	if err != nil {
+		return holder, "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return holder, "", err
+	}
+	age := time.Since(info.ModTime())
+	if err := json.Unmarshal(data, &holder); err != nil {
+		// Might still be being written
+		holder.Command = "unknown process"
+	}
+	hostname, _ := os.Hostname()
+	switch {
+	case age > StaleAfter:
+		return holder, fmt.Sprintf("not refreshed for %s", age.Truncate(time.Second)), nil
+	// The pid might belong to a live process in another PID namespace
+	// sharing the hostname, which would have refreshed the lock twice by now
+	case holder.PID != 0 && holder.Hostname == hostname && age > 2*RefreshInterval && !running(holder.PID):
+		return holder, "the process is gone", nil
+	}
+	return holder, "", nil
+}
+
+// removeIfHeldBy removes a lock file, unless it changed hands since it
+// was read.
+func removeIfHeldBy(path string, holder Holder) error {
+	var current Holder
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if json.Unmarshal(data, &current) == nil && !current.same(holder) {
+		return nil
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func running(pid int) bool {
+	if runtime.GOOS == "windows" {
+		// Signals can't tell, rely on StaleAfter
+		return true
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+)
+
+// DiscoverAssets regenerates the artifact file names of a github
+// service with an `assetPattern` from the assets of the release it's
+// pinned to: srcFilenames in version 3 manifests (only the ones that
+// differ from srcFilename, if there is one), the files of artifacts in
+// version 4 ones. It fails if a platform the service already names an
+// artifact for, or any supported platform if there are none, has no
+// matching asset.
+func DiscoverAssets(m *types.BoxManifest, service *types.Service) error {
+	release, err := github.GetRelease(service.Strategy.Project, service.ReleaseTag())
+	if err != nil {
+		return err
+	}
+	files, err := github.MatchAssets(service, service.Strategy.AssetPattern, release.Assets, assetPlatforms(service))
+	if err != nil {
+		return err
+	}
+	if m.Version != "4.0" {
+		service.SrcFilenames = exceptions(service, files)
+		return nil
+	}
+	// New platforms extract the same files as existing ones
+	var template *types.Artifact
+	for _, platArch := range assetPlatforms(service) {
+		if artifact, ok := service.Artifacts[platArch]; ok && template == nil {
+			template = artifact
+		}
+	}
+	if service.Artifacts == nil {
+		service.Artifacts = map[string]*types.Artifact{}
+	}
+	for platArch, file := range files {
+		artifact, ok := service.Artifacts[platArch]
+		if !ok {
+			artifact = &types.Artifact{}
+			if template != nil {
+				artifact.Extract = template.Extract
+			}
+			service.Artifacts[platArch] = artifact
+		}
+		if artifact.File != file {
+			// The digest was of the previous file
+			artifact.File, artifact.Digest = file, ""
+		}
+	}
+	return nil
+}
+
+// exceptions drops the files srcFilename already names, so that the
+// srcFilenames of templated services only list the ones it can't.
+func exceptions(service *types.Service, files map[string]string) map[string]string {
+	if service.SrcFilename == "" {
+		return files
+	}
+	var names map[string]string
+	for platArch, file := range files {
+		platform, architecture, _ := strings.Cut(platArch, "-")
+		if templated, err := service.Interpolate(service.SrcFilename, platform, architecture); err == nil && templated == file {
+			continue
+		}
+		if names == nil {
+			names = map[string]string{}
+		}
+		names[platArch] = file
+	}
+	return names
+}
+
+// assetPlatforms lists the platforms the service has artifacts for, or
+// every supported platform if it has none.
+func assetPlatforms(service *types.Service) []string {
+	var platforms []string
+	for platArch := range service.Artifacts {
+		platforms = append(platforms, platArch)
+	}
+	// The exceptions to a srcFilename don't limit the platforms
+	if service.SrcFilename == "" {
+		for platArch := range service.SrcFilenames {
+			platforms = append(platforms, platArch)
+		}
+	}
+	if len(platforms) == 0 {
+		return schema.SupportedPlatforms
+	}
+	sort.Strings(platforms)
+	return platforms
+}
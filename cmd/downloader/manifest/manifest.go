@@ -3,26 +3,47 @@ package manifest
 import (
 	"bytes"
 	"io/ioutil"
+	"strings"
 
 	"github.com/livepeer/catalyst/cmd/downloader/bucket"
 	"github.com/livepeer/catalyst/cmd/downloader/constants"
 	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
+	"github.com/livepeer/catalyst/cmd/downloader/semver"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
 	glog "github.com/magicsong/color-glog"
 	"gopkg.in/yaml.v3"
 )
 
 var version = "Unknown"
 
-func GenerateYamlManifest(manifest types.BoxManifest, path string) error {
+// EncodeYamlManifest serializes a manifest in the schema of its version.
+func EncodeYamlManifest(manifest *types.BoxManifest) ([]byte, error) {
+	var document interface{} = manifest
+	if manifest.Version == "4.0" {
+		v4, err := schema.ToV4(manifest)
+		if err != nil {
+			return nil, err
+		}
+		document = v4
+	}
 	var data bytes.Buffer
 	yamlEncoder := yaml.NewEncoder(&data)
 	yamlEncoder.SetIndent(2)
-	err := yamlEncoder.Encode(&manifest)
+	err := yamlEncoder.Encode(document)
+	if err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+func GenerateYamlManifest(manifest types.BoxManifest, path string) error {
+	data, err := EncodeYamlManifest(&manifest)
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(path, data.Bytes(), 0644)
+	err = ioutil.WriteFile(path, data, 0644)
 	return err
 }
 
@@ -33,27 +54,93 @@ func UpdateManifest(cliFlags types.CliFlags, m *types.BoxManifest) bool {
 	platform := cliFlags.Platform
 	architecture := cliFlags.Architecture
 
-	for _, service := range m.Box {
+	// Edits are patched into the lines of the file
+	if cliFlags.ManifestURL || cliFlags.ManifestFile == "-" {
+		glog.Errorf("not updating manifest: -update-manifest only rewrites manifest files")
+		return false
+	}
+	original, err := ioutil.ReadFile(cliFlags.ManifestFile)
+	if err != nil {
+		glog.Error(err)
+		return false
+	}
+	if format := utils.ManifestFormat(cliFlags.ManifestFile, original); format != utils.FormatYAML {
+		glog.Errorf("not updating manifest: -update-manifest only rewrites YAML manifests, %s is %s", cliFlags.ManifestFile, strings.ToUpper(format))
+		return false
+	}
+
+	// Resolving a copy, so that a failed update leaves the pins of the
+	// manifest to install alone
+	updated := *m
+	updated.Box = make([]*types.Service, len(m.Box))
+	for i, service := range m.Box {
+		updated.Box[i] = service.Clone()
+	}
+	var bumps []*Bump
+	for _, service := range updated.Box {
 		if service.Skip || service.SkipManifestUpdate {
 			continue
 		}
+		oldRelease, oldCommit := service.ReleaseTag(), service.Strategy.Commit
 		if service.Strategy.Download == "" {
 			service.Strategy.Download = "github"
 		}
+		// Resolve the head of the branch or the commit of the release,
+		// rather than installing the pinned commit
+		service.Strategy.Commit = ""
+		var err error
 		if service.Strategy.Download == "bucket" {
-			projectInfo = bucket.GetArtifactInfo(platform, architecture, m.Release, service)
+			projectInfo, err = bucket.GetArtifactInfo(platform, architecture, m.Release, service)
 		} else if service.Strategy.Download == "github" {
-			service.Release = constants.LatestTagReleaseName
-			projectInfo = github.GetArtifactInfo(platform, architecture, m.Release, service)
-			service.Release = projectInfo.Version
+			// Version ranges stay in the manifest, other releases track the latest one
+			tracksRange := semver.IsRange(service.Release)
+			if !tracksRange {
+				service.Release = constants.LatestTagReleaseName
+			}
+			service.PinnedRelease = ""
+			projectInfo, err = github.GetArtifactInfo(platform, architecture, m.Release, service)
+			if err != nil {
+				glog.Errorf("not updating manifest: %s", err)
+				return false
+			}
+			if !tracksRange {
+				service.Release = projectInfo.Version
+				service.PinnedRelease = ""
+			}
+			if service.Strategy.AssetPattern != "" {
+				if err := DiscoverAssets(&updated, service); err != nil {
+					glog.Errorf("not updating manifest: %s", err)
+					return false
+				}
+			}
+		}
+		if err != nil {
+			glog.Errorf("not updating manifest: %s", err)
+			return false
 		}
 		glog.V(8).Infof("gh-version=%q, manifest-version=%q", projectInfo.Version, service.Release)
+		if service.ReleaseTag() != oldRelease || service.Strategy.Commit != oldCommit {
+			bumps = append(bumps, newBump(service, oldRelease, oldCommit))
+		}
+	}
+	// Don't write out a combination of releases that doesn't work together
+	if err := CheckRequirements(&updated); err != nil {
+		glog.Errorf("not updating manifest: %s", err)
+		return false
+	}
+	*m = updated
+	data, err := RewriteManifest(original, m)
+	if err == nil {
+		err = ioutil.WriteFile(cliFlags.ManifestFile, data, 0644)
 	}
-	err := GenerateYamlManifest(*m, cliFlags.ManifestFile)
-
 	if err != nil {
 		glog.Error(err)
 		return false
 	}
+	if cliFlags.UpdateSummary != "" {
+		if err := WriteSummary(cliFlags.UpdateSummary, bumps); err != nil {
+			glog.Errorf("error writing update summary: %s", err)
+		}
+	}
 	return true
 }
@@ -0,0 +1,40 @@
+package manifest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedUpdateKeepsPins(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	previous := github.DefaultClient
+	defer func() { github.DefaultClient = previous }()
+	github.DefaultClient = github.NewClient(server.URL, "")
+	github.DefaultClient.CacheDir = ""
+
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`version: "3.0"
+box:
+  - name: livepeer
+    release: v0.7.2
+    strategy:
+      download: github
+      project: livepeer/go-livepeer
+      commit: 0846fae2b4c1e1d7a6d8c3e1f0a9b8c7d6e5f4a3
+`), 0644))
+	m, err := utils.ParseYamlManifest(path, false)
+	require.NoError(t, err)
+
+	require.False(t, UpdateManifest(types.CliFlags{ManifestFile: path, Platform: "linux", Architecture: "amd64"}, m))
+	require.Equal(t, "v0.7.2", m.Box[0].Release)
+	require.Equal(t, "0846fae2b4c1e1d7a6d8c3e1f0a9b8c7d6e5f4a3", m.Box[0].Strategy.Commit)
+}
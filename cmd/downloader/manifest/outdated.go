@@ -0,0 +1,213 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/bucket"
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/semver"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
+	glog "github.com/magicsong/color-glog"
+)
+
+// Staleness is how far a service of the manifest is behind the newest
+// release, or branch head, that its strategy offers.
+type Staleness struct {
+	Service  string `json:"service"`
+	Strategy string `json:"strategy"`
+	// Release as written in the manifest, e.g. a branch or version range
+	Release string `json:"release"`
+	// Release tag, or commit of bucket services, that gets installed
+	Current   string `json:"current"`
+	Available string `json:"available,omitempty"`
+	// Releases, or commits of bucket services, after current
+	Behind int `json:"behind"`
+	// Days since current was published, -1 if unknown
+	AgeDays  int  `json:"ageDays"`
+	Outdated bool `json:"outdated"`
+	// Whether -update-manifest leaves the service alone
+	Pinned  bool `json:"pinned"`
+	Skipped bool `json:"skipped"`
+	// Whether available is outside of the version range in release
+	OutOfRange bool   `json:"outOfRange,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Outdated prints, for every service of the manifest, the release or
+// commit it installs next to the newest one available, as a table or
+// as JSON. It doesn't change anything.
+func Outdated(cliFlags types.CliFlags) error {
+	m, err := utils.ParseYamlManifest(cliFlags.ManifestFile, cliFlags.ManifestURL)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var report []*Staleness
+	for _, service := range m.Box {
+		report = append(report, CheckStaleness(service, now))
+	}
+	if cliFlags.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return PrintOutdated(os.Stdout, report)
+}
+
+// CheckStaleness looks up the newest release or branch head of a
+// service. Lookup failures are recorded in the result.
+func CheckStaleness(service *types.Service, now time.Time) *Staleness {
+	st := &Staleness{
+		Service:  service.Name,
+		Strategy: service.Strategy.Download,
+		Release:  service.Release,
+		Current:  service.ReleaseTag(),
+		AgeDays:  -1,
+		Pinned:   service.SkipManifestUpdate,
+		Skipped:  service.Skip,
+	}
+	var err error
+	if service.Strategy.Download == "bucket" {
+		err = checkBranchHead(st, service, now)
+	} else {
+		var releases []*types.TagInformation
+		if releases, err = github.ListReleases(service.Strategy.Project); err == nil {
+			err = compareReleases(st, service, releases, now)
+		}
+	}
+	if err != nil {
+		glog.Warningf("couldn't check for updates of %s: %s", service.Name, err)
+		st.Error = err.Error()
+	}
+	return st
+}
+
+// compareReleases fills in how far a github service is behind the
+// newest release of its project.
+func compareReleases(st *Staleness, service *types.Service, releases []*types.TagInformation, now time.Time) error {
+	available, _, err := github.PickRelease(releases, constants.LatestTagReleaseName, service.AllowPrerelease)
+	if err != nil {
+		return err
+	}
+	st.Available = available
+	if st.Current == constants.LatestTagReleaseName {
+		// Always installs the newest release
+		st.Current = available
+	}
+	if _, err := semver.Parse(st.Current); err != nil {
+		st.Outdated = st.Current != available
+	}
+	for _, release := range github.ReleasesBetween(releases, st.Current, available) {
+		if !release.PreRelease || service.AllowPrerelease {
+			st.Behind++
+		}
+	}
+	st.Outdated = st.Outdated || st.Behind > 0
+	for _, release := range releases {
+		if release.TagName == st.Current {
+			st.AgeDays = ageDays(release.PublishedAt, now)
+		}
+	}
+	if semver.IsRange(service.Release) {
+		constraint, err := semver.ParseConstraint(service.Release)
+		version, versionErr := semver.Parse(available)
+		st.OutOfRange = err == nil && versionErr == nil && !constraint.Check(version)
+	}
+	return nil
+}
+
+// checkBranchHead fills in how far a bucket service is behind the
+// newest build of its branch.
+func checkBranchHead(st *Staleness, service *types.Service, now time.Time) error {
+	buildInfo, err := bucket.GetBuildInformation(bucket.BaseURL(service), utils.CleanBranchName(service.Release), service.Strategy.Project)
+	if err != nil {
+		return err
+	}
+	if buildInfo.Commit == "" {
+		return fmt.Errorf("no build found for branch %s", service.Release)
+	}
+	st.Available = buildInfo.Commit
+	st.Current = service.Strategy.Commit
+	if st.Current == "" {
+		// Always installs the head of the branch
+		st.Current = st.Available
+	}
+	st.Outdated = st.Current != st.Available
+	comparison, err := github.CompareCommits(serviceRepository(service), st.Current, st.Available)
+	if err != nil {
+		return err
+	}
+	compareCommits(st, comparison, now)
+	return nil
+}
+
+// compareCommits fills in how many commits a bucket service is behind
+// from the github comparison of its commit and the branch head.
+func compareCommits(st *Staleness, comparison *types.CompareInformation, now time.Time) {
+	st.Behind = comparison.TotalCommits
+	st.Outdated = st.Behind > 0
+	if comparison.BaseCommit != nil {
+		st.AgeDays = ageDays(comparison.BaseCommit.Commit.Committer.Date, now)
+	}
+}
+
+// PrintOutdated writes a staleness report as a table.
+func PrintOutdated(w io.Writer, report []*Staleness) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SERVICE\tCURRENT\tAVAILABLE\tBEHIND\tAGE\tNOTES")
+	for _, st := range report {
+		current, available := st.Current, st.Available
+		behind := fmt.Sprintf("%d releases", st.Behind)
+		if st.Strategy == "bucket" {
+			current = fmt.Sprintf("%s %s", st.Release, shortCommit(current))
+			if available != "" {
+				available = fmt.Sprintf("%s %s", st.Release, shortCommit(available))
+			}
+			behind = fmt.Sprintf("%d commits", st.Behind)
+		}
+		if !st.Outdated {
+			behind = "up to date"
+		}
+		age := "-"
+		if st.AgeDays >= 0 {
+			age = fmt.Sprintf("%dd", st.AgeDays)
+		}
+		var notes []string
+		if st.Pinned {
+			notes = append(notes, "pinned (skipManifestUpdate)")
+		}
+		if st.Skipped {
+			notes = append(notes, "skipped")
+		}
+		if st.OutOfRange {
+			notes = append(notes, fmt.Sprintf("outside of %q", st.Release))
+		}
+		if st.Error != "" {
+			behind = "-"
+			if available == "" {
+				available = "-"
+			}
+			notes = append(notes, "error: "+st.Error)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", st.Service, current, available, behind, age, strings.Join(notes, ", "))
+	}
+	return table.Flush()
+}
+
+// ageDays counts the whole days since an RFC 3339 time, or returns -1
+// if it can't be parsed.
+func ageDays(date string, now time.Time) int {
+	published, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return -1
+	}
+	return int(now.Sub(published).Hours() / 24)
+}
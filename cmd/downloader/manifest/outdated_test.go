@@ -0,0 +1,52 @@
+package manifest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+var outdatedNow = time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func TestCompareReleases(t *testing.T) {
+	releases := []*types.TagInformation{
+		{TagName: "v1.81.0-rc.1", PreRelease: true, PublishedAt: "2023-08-30T10:00:00Z"},
+		{TagName: "v1.80.0", PublishedAt: "2023-08-20T10:00:00Z"},
+		{TagName: "v1.79.2", PublishedAt: "2023-07-01T10:00:00Z"},
+		{TagName: "v1.79.1", PublishedAt: "2023-06-01T10:00:00Z"},
+	}
+	service := &types.Service{Name: "vm", Release: "~1.79", PinnedRelease: "v1.79.1", SkipManifestUpdate: true}
+	st := &Staleness{Current: service.ReleaseTag(), AgeDays: -1}
+	require.NoError(t, compareReleases(st, service, releases, outdatedNow))
+	require.Equal(t, &Staleness{Current: "v1.79.1", Available: "v1.80.0", Behind: 2, AgeDays: 92, Outdated: true, OutOfRange: true}, st)
+
+	service = &types.Service{Name: "vm", Release: "latest", AllowPrerelease: true}
+	st = &Staleness{Current: service.ReleaseTag(), AgeDays: -1}
+	require.NoError(t, compareReleases(st, service, releases, outdatedNow))
+	require.Equal(t, &Staleness{Current: "v1.81.0-rc.1", Available: "v1.81.0-rc.1", AgeDays: 2}, st)
+}
+
+func TestCompareCommits(t *testing.T) {
+	comparison := &types.CompareInformation{TotalCommits: 14, BaseCommit: &types.CommitInformation{SHA: "abc"}}
+	comparison.BaseCommit.Commit.Committer.Date = "2023-08-01T00:00:00Z"
+	st := &Staleness{AgeDays: -1}
+	compareCommits(st, comparison, outdatedNow)
+	require.Equal(t, &Staleness{Behind: 14, AgeDays: 31, Outdated: true}, st)
+}
+
+func TestPrintOutdated(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, PrintOutdated(&out, []*Staleness{
+		{Service: "catalyst-api", Strategy: "bucket", Release: "main", Current: "a4b62c993e41", Available: "5e1f0aa12345", Behind: 14, AgeDays: 40, Outdated: true},
+		{Service: "victoria-metrics", Strategy: "github", Release: "v1.79.1", Current: "v1.79.1", Available: "v1.93.0", Behind: 12, AgeDays: 400, Outdated: true, Pinned: true},
+		{Service: "api", Strategy: "github", Release: "v0.19.0", Current: "v0.19.0", AgeDays: -1, Error: "HTTP 403 while listing releases"},
+	}))
+	require.Equal(t, ""+
+		"SERVICE           CURRENT       AVAILABLE     BEHIND       AGE   NOTES\n"+
+		"catalyst-api      main a4b62c9  main 5e1f0aa  14 commits   40d   \n"+
+		"victoria-metrics  v1.79.1       v1.93.0       12 releases  400d  pinned (skipManifestUpdate)\n"+
+		"api               v0.19.0       -             -            -     error: HTTP 403 while listing releases\n", out.String())
+}
@@ -0,0 +1,229 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
+	glog "github.com/magicsong/color-glog"
+	"gopkg.in/yaml.v3"
+)
+
+// LocalOverlayPath returns the path of the developer overrides that are
+// picked up automatically next to a manifest, e.g. manifest.local.yaml
+// for manifest.yaml.
+func LocalOverlayPath(manifestFile string) string {
+	extension := filepath.Ext(manifestFile)
+	return strings.TrimSuffix(manifestFile, extension) + ".local" + extension
+}
+
+// Overlays lists the overlay files to apply on top of the manifest: the
+// local overrides next to a manifest file if they exist, followed by
+// the ones passed with -manifest-overlay.
+func Overlays(cliFlags types.CliFlags) []string {
+	var overlays []string
+	if !cliFlags.ManifestURL && utils.IsFileExists(LocalOverlayPath(cliFlags.ManifestFile)) {
+		overlays = append(overlays, LocalOverlayPath(cliFlags.ManifestFile))
+	}
+	return append(overlays, cliFlags.ManifestOverlays...)
+}
+
+// ApplyOverlays deep-merges overlay files onto a manifest in order.
+// Overlays use the schema of the manifest they apply to, but only need
+// to spell out what they change. Services are matched by name, services
+// unknown to the manifest are added to it. Overriding the `release` of
+// a service without pinning a commit unpins it, so the strategy picks
+// the head of the new branch or tag.
+func ApplyOverlays(m *types.BoxManifest, overlays []string) error {
+	for _, path := range overlays {
+		glog.Infof("applying manifest overlay %s", path)
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			data, err = utils.NormalizeManifest(path, data)
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyOverlay(m, data); err != nil {
+			return fmt.Errorf("error applying overlay %s: %w", path, err)
+		}
+	}
+	if len(overlays) == 0 {
+		return nil
+	}
+	m.PropagateVars()
+	// Overlays may add services, make sure the result is still complete
+	data, err := EncodeYamlManifest(m)
+	if err != nil {
+		return err
+	}
+	for _, issue := range schema.Validate(data) {
+		if issue.Severity == schema.SeverityError {
+			return fmt.Errorf("manifest with overlays applied is invalid: %s", issue.Message)
+		}
+	}
+	return nil
+}
+
+func applyOverlay(m *types.BoxManifest, data []byte) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("overlay must be a mapping")
+	}
+
+	// Version 4 services are merged in their own schema
+	if m.Version == "4.0" {
+		v4, err := schema.ToV4(m)
+		if err != nil {
+			return err
+		}
+		merged, err := applyOverlayV4(v4, root)
+		if err != nil {
+			return err
+		}
+		*m = *schema.FromV4(merged)
+		return nil
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "version":
+			if value.Value != m.Version {
+				return fmt.Errorf("overlay version %q doesn't match manifest version %q", value.Value, m.Version)
+			}
+		case "release":
+			m.Release = value.Value
+		case "vars":
+			if err := decodeStrict(value, &m.Vars); err != nil {
+				return err
+			}
+		case "groups":
+			if err := decodeStrict(value, &m.Groups); err != nil {
+				return err
+			}
+		case "box":
+			for _, item := range value.Content {
+				name := childValue(item, "name")
+				service := findService(m.Box, name)
+				if service == nil {
+					service = &types.Service{}
+					m.Box = append(m.Box, service)
+				}
+				if err := decodeStrict(item, service); err != nil {
+					return fmt.Errorf("service %q: %w", name, err)
+				}
+				if childValue(item, "release") != "" && childValue(item, "pinnedRelease") == "" {
+					service.PinnedRelease = ""
+				}
+				if childValue(item, "release") != "" && childValue(child(item, "strategy"), "commit") == "" && service.Strategy != nil {
+					service.Strategy.Commit = ""
+				}
+			}
+		default:
+			return fmt.Errorf("unknown key %q", key.Value)
+		}
+	}
+	return nil
+}
+
+func applyOverlayV4(m *types.BoxManifestV4, root *yaml.Node) (*types.BoxManifestV4, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "version":
+			if value.Value != m.Version {
+				return nil, fmt.Errorf("overlay version %q doesn't match manifest version %q", value.Value, m.Version)
+			}
+		case "release":
+			m.Release = value.Value
+		case "vars":
+			if err := decodeStrict(value, &m.Vars); err != nil {
+				return nil, err
+			}
+		case "groups":
+			if err := decodeStrict(value, &m.Groups); err != nil {
+				return nil, err
+			}
+		case "services":
+			for _, item := range value.Content {
+				name := childValue(item, "name")
+				var service *types.ServiceV4
+				for _, existing := range m.Services {
+					if existing.Name == name {
+						service = existing
+					}
+				}
+				if service == nil {
+					service = &types.ServiceV4{}
+					m.Services = append(m.Services, service)
+				}
+				if err := decodeStrict(item, service); err != nil {
+					return nil, fmt.Errorf("service %q: %w", name, err)
+				}
+				if childValue(item, "release") != "" && childValue(item, "pinnedRelease") == "" {
+					service.PinnedRelease = ""
+				}
+				if childValue(item, "release") != "" && childValue(item, "commit") == "" {
+					service.Commit = ""
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown key %q", key.Value)
+		}
+	}
+	return m, nil
+}
+
+// decodeStrict decodes a yaml node on top of an existing value, so that
+// only the keys present in the node are overwritten, and rejects keys
+// the value doesn't have.
+func decodeStrict(node *yaml.Node, out interface{}) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(out)
+}
+
+func findService(services []*types.Service, name string) *types.Service {
+	for _, service := range services {
+		if service.Name == name {
+			return service
+		}
+	}
+	return nil
+}
+
+func child(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func childValue(mapping *yaml.Node, key string) string {
+	if node := child(mapping, key); node != nil {
+		return node.Value
+	}
+	return ""
+}
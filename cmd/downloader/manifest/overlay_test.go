@@ -0,0 +1,100 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+func writeOverlay(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "overlay.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func testManifest() *types.BoxManifest {
+	return &types.BoxManifest{
+		Version: "3.0",
+		Release: "latest",
+		Box: []*types.Service{{
+			Name:         "livepeer",
+			Release:      "master",
+			Binary:       "livepeer",
+			SkipChecksum: true,
+			Strategy:     &types.DownloadStrategy{Download: "bucket", Project: "go-livepeer", Commit: "3acf4eab323df725f60feae6a8b288030cd58fb5"},
+		}},
+	}
+}
+
+func TestLocalOverlayPath(t *testing.T) {
+	require.Equal(t, "manifest.local.yaml", LocalOverlayPath("manifest.yaml"))
+	require.Equal(t, "/etc/catalyst/box.local.yml", LocalOverlayPath("/etc/catalyst/box.yml"))
+}
+
+func TestApplyOverlays(t *testing.T) {
+	m := testManifest()
+	branch := writeOverlay(t, `
+box:
+  - name: livepeer
+    release: my-feature-branch
+  - name: task-runner
+    release: main
+    strategy:
+      download: bucket
+      project: task-runner
+`)
+	pinned := writeOverlay(t, `
+box:
+  - name: livepeer
+    strategy:
+      commit: 0123456789abcdef0123456789abcdef01234567
+`)
+	require.NoError(t, ApplyOverlays(m, []string{branch, pinned}))
+	require.Len(t, m.Box, 2)
+	livepeer := m.Box[0]
+	require.Equal(t, "my-feature-branch", livepeer.Release)
+	require.Equal(t, "0123456789abcdef0123456789abcdef01234567", livepeer.Strategy.Commit)
+	// Keys the overlays don't mention are kept
+	require.Equal(t, "go-livepeer", livepeer.Strategy.Project)
+	require.Equal(t, "livepeer", livepeer.Binary)
+	require.True(t, livepeer.SkipChecksum)
+	require.Equal(t, "task-runner", m.Box[1].Name)
+}
+
+func TestApplyOverlayUnpinsRelease(t *testing.T) {
+	m := testManifest()
+	require.NoError(t, ApplyOverlays(m, []string{writeOverlay(t, "box:\n  - name: livepeer\n    release: my-feature-branch\n")}))
+	require.Equal(t, "", m.Box[0].Strategy.Commit)
+}
+
+func TestApplyOverlayV4(t *testing.T) {
+	m := testManifest()
+	m.Version = "4.0"
+	overlay := writeOverlay(t, `
+version: "4.0"
+services:
+  - name: livepeer
+    skipGpg: true
+`)
+	require.NoError(t, ApplyOverlays(m, []string{overlay}))
+	require.Equal(t, "4.0", m.Version)
+	require.True(t, m.Box[0].SkipGPG)
+	require.Equal(t, "3acf4eab323df725f60feae6a8b288030cd58fb5", m.Box[0].Strategy.Commit)
+	require.Equal(t, "bucket", m.Box[0].Strategy.Download)
+}
+
+func TestApplyOverlayErrors(t *testing.T) {
+	for name, overlay := range map[string]string{
+		"typo":             "box:\n  - name: livepeer\n    relase: master\n",
+		"version mismatch": "version: \"4.0\"\n",
+		"unknown key":      "services: []\n",
+		"incomplete":       "box:\n  - name: new-service\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, ApplyOverlays(testManifest(), []string{writeOverlay(t, overlay)}))
+		})
+	}
+}
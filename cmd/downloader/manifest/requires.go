@@ -0,0 +1,82 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/semver"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
+	glog "github.com/magicsong/color-glog"
+)
+
+// ErrRequirementsNotMet is returned when services of a manifest don't
+// fit together.
+var ErrRequirementsNotMet = errors.New("services have unmet requirements")
+
+// CheckRequirements checks the `requires` of the services that are
+// going to be installed against the releases and commits the manifest
+// has for the services they require, and explains every conflict.
+func CheckRequirements(m *types.BoxManifest) error {
+	services := map[string]*types.Service{}
+	for _, service := range m.Box {
+		services[service.Name] = service
+	}
+	var conflicts []string
+	for _, service := range m.Box {
+		if service.Skip {
+			continue
+		}
+		for _, requirement := range service.Requires {
+			required, ok := services[requirement.Service]
+			if !ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s requires %s, which isn't in the manifest", service.Name, requirement.Service))
+				continue
+			}
+			if required.Skip {
+				glog.Warningf("%s requires %s, which isn't being installed", service.Name, required.Name)
+				continue
+			}
+			if conflict := checkRequirement(service, required, requirement); conflict != "" {
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w:\n  %s", ErrRequirementsNotMet, strings.Join(conflicts, "\n  "))
+	}
+	return nil
+}
+
+func checkRequirement(service, required *types.Service, requirement *types.Requirement) string {
+	if requirement.Release != "" {
+		constraint, err := semver.ParseConstraint(requirement.Release)
+		if err != nil {
+			return fmt.Sprintf("%s: %s", service.Name, err)
+		}
+		version, err := semver.Parse(required.ReleaseTag())
+		if err != nil {
+			return fmt.Sprintf("%s requires %s release %q, but %s tracks %q, which isn't a version", service.Name, required.Name, requirement.Release, required.Name, required.ReleaseTag())
+		}
+		if !constraint.Check(version) {
+			return fmt.Sprintf("%s requires %s release %q, but the manifest has %s", service.Name, required.Name, requirement.Release, required.ReleaseTag())
+		}
+	}
+	if len(requirement.Commits) > 0 {
+		commit := ""
+		if required.Strategy != nil {
+			commit = required.Strategy.Commit
+		}
+		if commit == "" {
+			return fmt.Sprintf("%s requires %s at commit %s, but %s isn't pinned to a commit", service.Name, required.Name, strings.Join(requirement.Commits, " or "), required.Name)
+		}
+		for _, allowed := range requirement.Commits {
+			if utils.SameCommit(commit, allowed) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s requires %s at commit %s, but the manifest pins %s", service.Name, required.Name, strings.Join(requirement.Commits, " or "), commit)
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+func requirementsManifest(requires ...*types.Requirement) *types.BoxManifest {
+	return &types.BoxManifest{
+		Version: "3.0",
+		Box: []*types.Service{
+			{Name: "catalyst-api", Release: "main", Strategy: &types.DownloadStrategy{Commit: "a4b62c993e4160a45e3865644d5db0f5133d511e"}, Requires: requires},
+			{Name: "mistserver", Release: "catalyst", Strategy: &types.DownloadStrategy{Commit: "0846fae8c0cae4296f49c281b71e1b1052c623c4"}},
+			{Name: "api", Release: "v0.19.0", Strategy: &types.DownloadStrategy{}},
+		},
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	require.NoError(t, CheckRequirements(requirementsManifest(
+		&types.Requirement{Service: "mistserver", Commits: []string{"1234567", "0846fae"}},
+		&types.Requirement{Service: "api", Release: "~0.19"},
+	)))
+
+	m := requirementsManifest(
+		&types.Requirement{Service: "mistserver", Commits: []string{"1234567"}},
+		&types.Requirement{Service: "api", Release: ">=0.20"},
+		&types.Requirement{Service: "mistserver", Release: ">=3.2"},
+	)
+	require.EqualError(t, CheckRequirements(m), `services have unmet requirements:
+  catalyst-api requires mistserver at commit 1234567, but the manifest pins 0846fae8c0cae4296f49c281b71e1b1052c623c4
+  catalyst-api requires api release ">=0.20", but the manifest has v0.19.0
+  catalyst-api requires mistserver release ">=3.2", but mistserver tracks "catalyst", which isn't a version`)
+
+	// Requirements of services that aren't installed don't matter
+	m.Box[0].Skip = true
+	require.NoError(t, CheckRequirements(m))
+}
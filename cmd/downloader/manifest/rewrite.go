@@ -0,0 +1,431 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+	"gopkg.in/yaml.v3"
+)
+
+// change sets a key of a yaml mapping to a scalar value, or removes the
+// key if the value is empty. A missing key is added after the first of
+// the keys in after that the mapping has. Keys with a node are set to
+// that node instead, which is how mappings are added.
+type change struct {
+	mapping *yaml.Node
+	key     string
+	value   string
+	node    *yaml.Node
+	after   []string
+}
+
+// RewriteManifest writes the releases and commits of m into the yaml
+// manifest m was read from. Only the lines of the values that changed
+// are edited, so comments, anchors, quoting and blank lines survive.
+// Layouts that can't be edited line by line, e.g. flow mappings, are
+// rewritten through the yaml tree instead, which keeps comments but
+// not blank lines.
+func RewriteManifest(original []byte, m *types.BoxManifest) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(original, &document); err != nil {
+		return nil, err
+	}
+	changes, err := manifestChanges(&document, m)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return original, nil
+	}
+	if data, ok := patchLines(original, changes); ok && rewritten(data, m) {
+		return data, nil
+	}
+	glog.V(5).Infof("manifest can't be edited line by line, rewriting it")
+	for _, change := range changes {
+		applyChange(change)
+	}
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// manifestChanges lists the updates to make to the services of a
+// manifest document.
+func manifestChanges(document *yaml.Node, m *types.BoxManifest) ([]*change, error) {
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("manifest isn't a yaml mapping")
+	}
+	root := document.Content[0]
+	servicesKey := "box"
+	if m.Version == "4.0" {
+		servicesKey = "services"
+	}
+	nodes := map[string]*yaml.Node{}
+	for _, node := range resolveAlias(child(root, servicesKey)).Content {
+		node = resolveAlias(node)
+		nodes[childValue(node, "name")] = node
+	}
+
+	var changes []*change
+	for _, service := range m.Box {
+		node := nodes[service.Name]
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("service %q not found in manifest", service.Name)
+		}
+		commit := ""
+		if service.Strategy != nil {
+			commit = service.Strategy.Commit
+		}
+		changes = append(changes,
+			&change{mapping: node, key: "release", value: service.Release, after: []string{"name"}},
+			&change{mapping: node, key: "pinnedRelease", value: service.PinnedRelease, after: []string{"release", "name"}},
+		)
+		if m.Version == "4.0" {
+			changes = append(changes, &change{mapping: node, key: "commit", value: commit, after: []string{"pinnedRelease", "release", "name"}})
+		} else if strategy := resolveAlias(child(node, "strategy")); strategy != nil {
+			changes = append(changes, &change{mapping: strategy, key: "commit", value: commit, after: []string{"project", "download"}})
+		}
+		// Discovered artifact names
+		if service.Strategy != nil && service.Strategy.AssetPattern != "" {
+			if m.Version == "4.0" {
+				changes = append(changes, artifactChanges(node, service.Artifacts)...)
+			} else {
+				changes = append(changes, mappingChanges(node, "srcFilenames", service.SrcFilenames, []string{"srcFilename", "release", "name"})...)
+			}
+		}
+	}
+
+	// Drop the changes that don't change anything
+	var needed []*change
+	for _, change := range changes {
+		if change.node != nil {
+			needed = append(needed, change)
+			continue
+		}
+		current := resolveAlias(child(change.mapping, change.key))
+		if current == nil && change.value == "" {
+			continue
+		}
+		if current != nil && current.Kind == yaml.ScalarNode && current.Value == change.value {
+			continue
+		}
+		needed = append(needed, change)
+	}
+	return needed, nil
+}
+
+// mappingChanges updates a mapping of strings under key entry by entry,
+// adds it whole if it's missing, or removes it if there are no values.
+func mappingChanges(parent *yaml.Node, key string, values map[string]string, after []string) []*change {
+	mapping := resolveAlias(child(parent, key))
+	if len(values) == 0 {
+		if mapping == nil {
+			return nil
+		}
+		return []*change{{mapping: parent, key: key}}
+	}
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return []*change{{mapping: parent, key: key, node: encodeNode(values), after: after}}
+	}
+	var changes []*change
+	keys := sortedKeys(values)
+	for i, entry := range keys {
+		changes = append(changes, &change{mapping: mapping, key: entry, value: values[entry], after: preceding(keys, i)})
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if _, ok := values[mapping.Content[i].Value]; !ok {
+			changes = append(changes, &change{mapping: mapping, key: mapping.Content[i].Value})
+		}
+	}
+	return changes
+}
+
+// artifactChanges updates the files of the artifacts of a version 4
+// service, and adds the artifacts of new platforms.
+func artifactChanges(service *yaml.Node, artifacts map[string]*types.Artifact) []*change {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	mapping := resolveAlias(child(service, "artifacts"))
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return []*change{{mapping: service, key: "artifacts", node: encodeNode(artifacts), after: []string{"commit", "pinnedRelease", "release", "name"}}}
+	}
+	var platforms []string
+	for platArch := range artifacts {
+		platforms = append(platforms, platArch)
+	}
+	sort.Strings(platforms)
+	var changes []*change
+	for i, platArch := range platforms {
+		artifact := artifacts[platArch]
+		entry := resolveAlias(child(mapping, platArch))
+		if entry == nil || entry.Kind != yaml.MappingNode {
+			changes = append(changes, &change{mapping: mapping, key: platArch, node: encodeNode(artifact), after: preceding(platforms, i)})
+			continue
+		}
+		changes = append(changes,
+			&change{mapping: entry, key: "file", value: artifact.File},
+			&change{mapping: entry, key: "digest", value: artifact.Digest, after: []string{"file"}},
+		)
+	}
+	return changes
+}
+
+// preceding lists the keys before the i-th one, closest first.
+func preceding(keys []string, i int) []string {
+	var previous []string
+	for j := i - 1; j >= 0; j-- {
+		previous = append(previous, keys[j])
+	}
+	return previous
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func encodeNode(value interface{}) *yaml.Node {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		// Only called with maps and structs of strings
+		panic(err)
+	}
+	return &node
+}
+
+// lineEdit replaces the text between two columns of a line, inserts a
+// line after it, or deletes it.
+type lineEdit struct {
+	line       int
+	start, end int
+	text       string
+	insert     bool
+	delete     bool
+}
+
+// patchLines applies the changes to the text of the manifest. It
+// reports false for changes it can't make line by line.
+func patchLines(original []byte, changes []*change) ([]byte, bool) {
+	lines := strings.Split(string(original), "\n")
+	var edits []lineEdit
+	for _, change := range changes {
+		if change.mapping.Style&yaml.FlowStyle != 0 {
+			return nil, false
+		}
+		key, value := childNodes(change.mapping, change.key)
+		switch {
+		case value != nil && (change.node != nil || value.Kind != yaml.ScalarNode || value.Anchor != ""):
+			return nil, false
+		case value != nil && change.value == "":
+			if key.Line != value.Line {
+				return nil, false
+			}
+			edits = append(edits, lineEdit{line: key.Line, delete: true})
+		case value != nil:
+			line := lines[value.Line-1]
+			start := value.Column - 1
+			end, ok := scalarEnd(line, start, value.Style)
+			if !ok {
+				return nil, false
+			}
+			edits = append(edits, lineEdit{line: value.Line, start: start, end: end, text: formatScalar(change.value, value.Style)})
+		default:
+			var anchor, anchorValue *yaml.Node
+			for _, name := range change.after {
+				if anchor, anchorValue = childNodes(change.mapping, name); anchor != nil {
+					break
+				}
+			}
+			if anchor == nil || anchorValue.Kind != yaml.ScalarNode || anchorValue.Line != anchor.Line {
+				return nil, false
+			}
+			if _, ok := scalarEnd(lines[anchor.Line-1], anchorValue.Column-1, anchorValue.Style); !ok {
+				return nil, false
+			}
+			indent := strings.Repeat(" ", anchor.Column-1)
+			text := fmt.Sprintf("%s%s: %s", indent, change.key, formatScalar(change.value, 0))
+			if change.node != nil {
+				block, err := formatBlock(change.key, change.node)
+				if err != nil {
+					return nil, false
+				}
+				text = indent + strings.ReplaceAll(block, "\n", "\n"+indent)
+			}
+			edits = append(edits, lineEdit{line: anchor.Line, text: text, insert: true})
+		}
+	}
+
+	// Edit from the bottom up, so line numbers stay valid. Lines inserted
+	// after the same line are inserted last to first, to keep their order.
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line > edits[j].line
+		}
+		return edits[i].start > edits[j].start
+	})
+	for _, edit := range edits {
+		i := edit.line - 1
+		switch {
+		case edit.delete:
+			lines = append(lines[:i], lines[i+1:]...)
+		case edit.insert:
+			lines = append(lines[:i+1], append([]string{edit.text}, lines[i+1:]...)...)
+		default:
+			lines[i] = lines[i][:edit.start] + edit.text + lines[i][edit.end:]
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), true
+}
+
+// scalarEnd finds where a single line scalar starting at start ends.
+func scalarEnd(line string, start int, style yaml.Style) (int, bool) {
+	if start >= len(line) {
+		return 0, false
+	}
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' && i+1 < len(line) && line[i+1] == '\'' {
+				i++
+			} else if line[i] == '\'' {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return 0, false
+	}
+	end := len(line)
+	if comment := strings.Index(line[start:], " #"); comment >= 0 {
+		end = start + comment
+	}
+	return len(strings.TrimRight(line[:end], " \t\r")), true
+}
+
+// formatScalar writes a value in the quoting style of the scalar it
+// replaces, quoting plain values that wouldn't read back as strings.
+func formatScalar(value string, style yaml.Style) string {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(value)
+	case style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return strconv.Quote(value)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// formatBlock writes a key and a node in block style.
+func formatBlock(key string, node *yaml.Node) (string, error) {
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, node}}
+	if err := encoder.Encode(mapping); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(data.String(), "\n"), nil
+}
+
+// rewritten checks that a patched manifest reads back with the
+// releases and commits of m.
+func rewritten(data []byte, m *types.BoxManifest) bool {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return false
+	}
+	changes, err := manifestChanges(&document, m)
+	return err == nil && len(changes) == 0
+}
+
+// applyChange makes a change in the yaml tree.
+func applyChange(change *change) {
+	mapping := change.mapping
+	if i := keyIndex(mapping, change.key); i >= 0 {
+		if change.node != nil {
+			mapping.Content[i+1] = change.node
+			return
+		}
+		if change.value == "" {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+		value := mapping.Content[i+1]
+		if value.Kind == yaml.ScalarNode && value.Anchor != "" {
+			// Aliases of the value change with it
+			value.Value = change.value
+			return
+		}
+		// Replace rather than edit aliases
+		mapping.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: change.value}
+		if value.Kind == yaml.ScalarNode {
+			mapping.Content[i+1].Style = value.Style &^ yaml.TaggedStyle
+		}
+		return
+	}
+	position := len(mapping.Content)
+	for _, name := range change.after {
+		if i := keyIndex(mapping, name); i >= 0 {
+			position = i + 2
+			break
+		}
+	}
+	value := change.node
+	if value == nil {
+		value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: change.value}
+	}
+	nodes := []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: change.key}, value}
+	mapping.Content = append(mapping.Content[:position], append(nodes, mapping.Content[position:]...)...)
+}
+
+func keyIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func childNodes(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if i := keyIndex(mapping, key); i >= 0 {
+		return mapping.Content[i], mapping.Content[i+1]
+	}
+	return nil, nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	if node != nil && node.Kind == yaml.AliasNode {
+		return node.Alias
+	}
+	return node
+}
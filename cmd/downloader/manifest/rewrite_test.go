@@ -0,0 +1,257 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const commentedManifest = `# Services of the catalyst image
+version: "3.0"
+release: latest
+
+box:
+  # Media server
+  - name: mistserver
+    strategy:
+      download: bucket
+      project: mistserver
+      commit: 0846fae8c0cae4296f49c281b71e1b1052c623c4 # don't bump without testing
+    release: catalyst
+
+  - name: api
+    strategy: &studio
+      download: github
+      project: livepeer/studio
+    release: "v0.19.0"
+    pinnedRelease: v0.19.0
+`
+
+func readManifest(t *testing.T, data string) *types.BoxManifest {
+	var m types.BoxManifest
+	require.NoError(t, yaml.Unmarshal([]byte(data), &m))
+	return &m
+}
+
+func TestRewriteManifestEditsLines(t *testing.T) {
+	m := readManifest(t, commentedManifest)
+	m.Box[0].Strategy.Commit = "1a2b3c4d5e6f1a2b3c4d5e6f1a2b3c4d5e6f1a2b"
+	m.Box[1].Release = "v0.20.1"
+	m.Box[1].PinnedRelease = ""
+	m.Box[1].Strategy.Commit = "8e2203e36c1b60d85698647ae7d5e3e069b0023a"
+
+	data, err := RewriteManifest([]byte(commentedManifest), m)
+	require.NoError(t, err)
+	require.Equal(t, `# Services of the catalyst image
+version: "3.0"
+release: latest
+
+box:
+  # Media server
+  - name: mistserver
+    strategy:
+      download: bucket
+      project: mistserver
+      commit: 1a2b3c4d5e6f1a2b3c4d5e6f1a2b3c4d5e6f1a2b # don't bump without testing
+    release: catalyst
+
+  - name: api
+    strategy: &studio
+      download: github
+      project: livepeer/studio
+      commit: 8e2203e36c1b60d85698647ae7d5e3e069b0023a
+    release: "v0.20.1"
+`, string(data))
+}
+
+func TestRewriteManifestUnchanged(t *testing.T) {
+	data, err := RewriteManifest([]byte(commentedManifest), readManifest(t, commentedManifest))
+	require.NoError(t, err)
+	require.Equal(t, commentedManifest, string(data))
+}
+
+func TestRewriteManifestVersion4(t *testing.T) {
+	original := `version: "4.0"
+services:
+  - name: api
+    release: ~0.19 # stay on 0.19
+    strategy:
+      github:
+        project: livepeer/studio
+`
+	m := readManifest(t, `version: "4.0"`)
+	m.Box = []*types.Service{{Name: "api", Release: "~0.19", PinnedRelease: "v0.19.4", Strategy: &types.DownloadStrategy{Commit: "8e2203e36c1b60d85698647ae7d5e3e069b0023a"}}}
+	data, err := RewriteManifest([]byte(original), m)
+	require.NoError(t, err)
+	require.Equal(t, `version: "4.0"
+services:
+  - name: api
+    release: ~0.19 # stay on 0.19
+    pinnedRelease: v0.19.4
+    commit: 8e2203e36c1b60d85698647ae7d5e3e069b0023a
+    strategy:
+      github:
+        project: livepeer/studio
+`, string(data))
+}
+
+func TestRewriteManifestFlowStyle(t *testing.T) {
+	original := `version: "3.0"
+box:
+  # Monitoring
+  - {name: vmagent, release: v1.80.0, strategy: {project: VictoriaMetrics/VictoriaMetrics}}
+`
+	m := readManifest(t, original)
+	m.Box[0].Release = "v1.81.0"
+	data, err := RewriteManifest([]byte(original), m)
+	require.NoError(t, err)
+	require.Equal(t, `version: "3.0"
+box:
+  # Monitoring
+  - {name: vmagent, release: v1.81.0, strategy: {project: VictoriaMetrics/VictoriaMetrics}}
+`, string(data))
+}
+
+func TestRewriteManifestSrcFilenames(t *testing.T) {
+	original := `version: "3.0"
+box:
+  - name: vmagent
+    strategy:
+      download: github
+      project: VictoriaMetrics/VictoriaMetrics
+      assetPattern: vmutils-${platform}-${arch}-${release}.${ext}
+    release: v1.79.1
+    skipGpg: true
+  - name: victoria-metrics
+    strategy:
+      download: github
+      project: VictoriaMetrics/VictoriaMetrics
+      assetPattern: victoria-metrics-${platform}-${arch}-${release}.${ext}
+    release: v1.79.1
+    srcFilenames:
+      linux-amd64: victoria-metrics-linux-amd64-v1.79.1.tar.gz # server builds
+      windows-amd64: victoria-metrics-windows-amd64-v1.79.1.zip
+`
+	m := readManifest(t, original)
+	m.Box[0].Release = "v1.80.0"
+	m.Box[0].SrcFilenames = map[string]string{"linux-amd64": "vmutils-linux-amd64-v1.80.0.tar.gz", "darwin-arm64": "vmutils-darwin-arm64-v1.80.0.tar.gz"}
+	m.Box[1].Release = "v1.80.0"
+	m.Box[1].SrcFilenames = map[string]string{"linux-amd64": "victoria-metrics-linux-amd64-v1.80.0.tar.gz", "linux-arm64": "victoria-metrics-linux-arm64-v1.80.0.tar.gz"}
+	data, err := RewriteManifest([]byte(original), m)
+	require.NoError(t, err)
+	require.Equal(t, `version: "3.0"
+box:
+  - name: vmagent
+    strategy:
+      download: github
+      project: VictoriaMetrics/VictoriaMetrics
+      assetPattern: vmutils-${platform}-${arch}-${release}.${ext}
+    release: v1.80.0
+    srcFilenames:
+      darwin-arm64: vmutils-darwin-arm64-v1.80.0.tar.gz
+      linux-amd64: vmutils-linux-amd64-v1.80.0.tar.gz
+    skipGpg: true
+  - name: victoria-metrics
+    strategy:
+      download: github
+      project: VictoriaMetrics/VictoriaMetrics
+      assetPattern: victoria-metrics-${platform}-${arch}-${release}.${ext}
+    release: v1.80.0
+    srcFilenames:
+      linux-amd64: victoria-metrics-linux-amd64-v1.80.0.tar.gz # server builds
+      linux-arm64: victoria-metrics-linux-arm64-v1.80.0.tar.gz
+`, string(data))
+}
+
+func TestRewriteManifestSrcFilenameExceptions(t *testing.T) {
+	original := `version: "3.0"
+box:
+  - name: vmagent
+    strategy:
+      download: github
+      project: VictoriaMetrics/VictoriaMetrics
+      assetPattern: vmutils-${platform}-${arch}-*.${ext}
+    release: v1.79.1
+    srcFilename: vmutils-${platform}-${arch}-${release}.${ext}
+    srcFilenames:
+      darwin-arm64: vmutils-darwin-arm64-v1.79.1-signed.tar.gz
+    skipGpg: true
+`
+	m := readManifest(t, original)
+	m.Box[0].Release = "v1.80.0"
+	m.Box[0].SrcFilenames = exceptions(m.Box[0], map[string]string{
+		"darwin-arm64": "vmutils-darwin-arm64-v1.80.0.tar.gz",
+		"linux-amd64":  "vmutils-linux-amd64-v1.80.0.tar.gz",
+	})
+	require.Nil(t, m.Box[0].SrcFilenames, "srcFilename names all of them")
+	data, err := RewriteManifest([]byte(original), m)
+	require.NoError(t, err)
+	require.Equal(t, `version: "3.0"
+box:
+  - name: vmagent
+    strategy:
+      download: github
+      project: VictoriaMetrics/VictoriaMetrics
+      assetPattern: vmutils-${platform}-${arch}-*.${ext}
+    release: v1.80.0
+    srcFilename: vmutils-${platform}-${arch}-${release}.${ext}
+    skipGpg: true
+`, string(data))
+
+	m.Box[0].SrcFilenames = exceptions(m.Box[0], map[string]string{
+		"darwin-arm64": "vmutils-darwin-arm64-v1.80.0-signed.tar.gz",
+		"linux-amd64":  "vmutils-linux-amd64-v1.80.0.tar.gz",
+	})
+	require.Equal(t, map[string]string{"darwin-arm64": "vmutils-darwin-arm64-v1.80.0-signed.tar.gz"}, m.Box[0].SrcFilenames)
+}
+
+func TestRewriteManifestArtifacts(t *testing.T) {
+	original := `version: "4.0"
+services:
+  - name: vmagent
+    release: v1.79.1
+    strategy:
+      github:
+        project: VictoriaMetrics/VictoriaMetrics
+        assetPattern: vmutils-${platform}-${arch}-${release}.${ext}
+    artifacts:
+      linux-amd64:
+        file: vmutils-linux-amd64-v1.79.1.tar.gz
+        digest: sha256:0123
+        extract:
+          - path: vmagent-prod
+`
+	m := readManifest(t, `version: "4.0"`)
+	extract := []*types.ExtractFile{{Path: "vmagent-prod"}}
+	m.Box = []*types.Service{{
+		Name:     "vmagent",
+		Release:  "v1.80.0",
+		Strategy: &types.DownloadStrategy{Download: "github", AssetPattern: "vmutils-${platform}-${arch}-${release}.${ext}"},
+		Artifacts: map[string]*types.Artifact{
+			"linux-amd64": {File: "vmutils-linux-amd64-v1.80.0.tar.gz", Extract: extract},
+			"linux-arm64": {File: "vmutils-linux-arm64-v1.80.0.tar.gz", Extract: extract},
+		},
+	}}
+	data, err := RewriteManifest([]byte(original), m)
+	require.NoError(t, err)
+	require.Equal(t, `version: "4.0"
+services:
+  - name: vmagent
+    release: v1.80.0
+    strategy:
+      github:
+        project: VictoriaMetrics/VictoriaMetrics
+        assetPattern: vmutils-${platform}-${arch}-${release}.${ext}
+    artifacts:
+      linux-amd64:
+        file: vmutils-linux-amd64-v1.80.0.tar.gz
+        extract:
+          - path: vmagent-prod
+      linux-arm64:
+        file: vmutils-linux-arm64-v1.80.0.tar.gz
+        extract:
+          - path: vmagent-prod
+`, string(data))
+}
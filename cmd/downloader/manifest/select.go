@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+// Select marks the services that -only, -group and -exclude leave out
+// as skipped. Without -only and -group every service that isn't
+// skipped in the manifest is installed. Services named in -only are
+// installed even when the manifest skips them.
+func Select(m *types.BoxManifest, cliFlags types.CliFlags) error {
+	only, group, exclude := splitList(cliFlags.Only), splitList(cliFlags.Group), splitList(cliFlags.Exclude)
+	if len(only) == 0 && len(group) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	selected := map[string]bool{}
+	for _, service := range m.Box {
+		selected[service.Name] = !service.Skip && len(only) == 0 && len(group) == 0
+	}
+	for _, name := range only {
+		if _, ok := selected[name]; !ok {
+			return fmt.Errorf("-only: unknown service %q", name)
+		}
+		selected[name] = true
+	}
+	for _, name := range group {
+		members, err := groupMembers(m, name)
+		if err != nil {
+			return fmt.Errorf("-group: %w", err)
+		}
+		for _, service := range members {
+			selected[service.Name] = selected[service.Name] || !service.Skip
+		}
+	}
+	for _, name := range exclude {
+		members, err := groupMembers(m, name)
+		if err != nil {
+			return fmt.Errorf("-exclude: %w", err)
+		}
+		for _, service := range members {
+			selected[service.Name] = false
+		}
+	}
+
+	var installing []string
+	for _, service := range m.Box {
+		service.Skip = !selected[service.Name]
+		if !service.Skip {
+			installing = append(installing, service.Name)
+		}
+	}
+	sort.Strings(installing)
+	glog.Infof("selected services: %s", strings.Join(installing, ", "))
+	return nil
+}
+
+// groupMembers returns the services in a group of the manifest, or
+// the services named or tagged name. Groups list service names and
+// tags.
+func groupMembers(m *types.BoxManifest, name string) ([]*types.Service, error) {
+	if names, ok := m.Groups[name]; ok {
+		var members []*types.Service
+		for _, member := range names {
+			services, err := groupMembers(&types.BoxManifest{Box: m.Box}, member)
+			if err != nil {
+				return nil, fmt.Errorf("group %q: %w", name, err)
+			}
+			members = append(members, services...)
+		}
+		return members, nil
+	}
+	var members []*types.Service
+	for _, service := range m.Box {
+		if service.Name == name {
+			members = append(members, service)
+			continue
+		}
+		for _, tag := range service.Tags {
+			if tag == name {
+				members = append(members, service)
+				break
+			}
+		}
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no service or group named or tagged %q", name)
+	}
+	return members, nil
+}
+
+// splitList flattens repeated and comma-separated flag values.
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+func selectionManifest() *types.BoxManifest {
+	return &types.BoxManifest{
+		Version: "3.0",
+		Groups:  map[string][]string{"box": {"media", "monitoring", "api"}},
+		Box: []*types.Service{
+			{Name: "api"},
+			{Name: "catalyst-api", Tags: []string{"media"}},
+			{Name: "livepeer", Tags: []string{"media"}},
+			{Name: "mistserver", Tags: []string{"media"}},
+			{Name: "vmagent", Tags: []string{"monitoring"}},
+			{Name: "task-runner", Skip: true},
+		},
+	}
+}
+
+func installed(m *types.BoxManifest) []string {
+	var names []string
+	for _, service := range m.Box {
+		if !service.Skip {
+			names = append(names, service.Name)
+		}
+	}
+	return names
+}
+
+func TestSelect(t *testing.T) {
+	for name, tc := range map[string]struct {
+		flags    types.CliFlags
+		expected []string
+	}{
+		"everything":   {types.CliFlags{}, []string{"api", "catalyst-api", "livepeer", "mistserver", "vmagent"}},
+		"tag":          {types.CliFlags{Group: []string{"media"}}, []string{"catalyst-api", "livepeer", "mistserver"}},
+		"group":        {types.CliFlags{Group: []string{"box"}, Exclude: []string{"livepeer"}}, []string{"api", "catalyst-api", "mistserver", "vmagent"}},
+		"only":         {types.CliFlags{Only: []string{"api,task-runner"}}, []string{"api", "task-runner"}},
+		"only + group": {types.CliFlags{Only: []string{"task-runner"}, Group: []string{"monitoring"}}, []string{"vmagent", "task-runner"}},
+		"exclude":      {types.CliFlags{Exclude: []string{"media", "api"}}, []string{"vmagent"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := selectionManifest()
+			require.NoError(t, Select(m, tc.flags))
+			require.Equal(t, tc.expected, installed(m))
+		})
+	}
+}
+
+func TestSelectUnknown(t *testing.T) {
+	require.EqualError(t, Select(selectionManifest(), types.CliFlags{Only: []string{"mist"}}), `-only: unknown service "mist"`)
+	require.EqualError(t, Select(selectionManifest(), types.CliFlags{Group: []string{"transcoding"}}), `-group: no service or group named or tagged "transcoding"`)
+}
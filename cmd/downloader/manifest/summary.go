@@ -0,0 +1,182 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/github"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	glog "github.com/magicsong/color-glog"
+)
+
+// Commit titles listed per service in a summary, the compare link has
+// the rest.
+const maxSummaryCommits = 30
+
+// Bump is a service that a manifest update moved to another release or
+// commit, along with what changed in between.
+type Bump struct {
+	Service    string
+	Repository string
+	// Whether releases are github release tags, rather than branches
+	Tagged     bool
+	OldRelease string
+	NewRelease string
+	OldCommit  string
+	NewCommit  string
+	Releases   []*types.TagInformation
+	Commits    *types.CompareInformation
+	// Why the changes couldn't be fetched
+	Err error
+}
+
+func newBump(service *types.Service, oldRelease, oldCommit string) *Bump {
+	return &Bump{
+		Service:    service.Name,
+		Repository: serviceRepository(service),
+		Tagged:     service.Strategy.Download != "bucket",
+		OldRelease: oldRelease,
+		NewRelease: service.ReleaseTag(),
+		OldCommit:  oldCommit,
+		NewCommit:  service.Strategy.Commit,
+	}
+}
+
+// FetchChanges looks up the release notes between the releases of a
+// tagged service, or else the commits between its commits.
+func (b *Bump) FetchChanges() {
+	if b.Tagged && b.OldRelease != b.NewRelease {
+		releases, err := github.ListReleases(b.Repository)
+		if err != nil {
+			b.Err = err
+			return
+		}
+		b.Releases = github.ReleasesBetween(releases, b.OldRelease, b.NewRelease)
+		if len(b.Releases) > 0 {
+			return
+		}
+	}
+	if b.OldCommit == "" || b.NewCommit == "" {
+		return
+	}
+	b.Commits, b.Err = github.CompareCommits(b.Repository, b.OldCommit, b.NewCommit)
+}
+
+// CompareURL links to the diff of the bump on github.
+func (b *Bump) CompareURL() string {
+	if b.Tagged && b.OldRelease != "" && b.NewRelease != "" && b.OldRelease != b.NewRelease {
+		return github.WebURL(constants.GitHubComparePathFormat, b.Repository, b.OldRelease, b.NewRelease)
+	}
+	if b.OldCommit == "" || b.NewCommit == "" {
+		return ""
+	}
+	return github.WebURL(constants.GitHubComparePathFormat, b.Repository, shortCommit(b.OldCommit), shortCommit(b.NewCommit))
+}
+
+// Markdown describes the bump, ready to paste into a pull request.
+func (b *Bump) Markdown() string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "### %s\n\n", b.Service)
+	describe := func(release, commit string) string {
+		switch {
+		case b.Tagged && b.OldRelease != b.NewRelease:
+			return release
+		case commit == "":
+			return fmt.Sprintf("`%s`", release)
+		}
+		return fmt.Sprintf("`%s` %s", release, shortCommit(commit))
+	}
+	from, to := describe(b.OldRelease, b.OldCommit), describe(b.NewRelease, b.NewCommit)
+	fmt.Fprintf(&md, "%s → %s", from, to)
+	if url := b.CompareURL(); url != "" {
+		fmt.Fprintf(&md, " · [compare](%s)", url)
+	}
+	md.WriteString("\n")
+
+	switch {
+	case b.Err != nil:
+		fmt.Fprintf(&md, "\n_Couldn't fetch the changes: %s_\n", b.Err)
+	case len(b.Releases) > 0:
+		md.WriteString("\n<details>\n<summary>Release notes</summary>\n")
+		for _, release := range b.Releases {
+			title := release.TagName
+			if release.HTMLURL != "" {
+				title = fmt.Sprintf("[%s](%s)", release.TagName, release.HTMLURL)
+			}
+			fmt.Fprintf(&md, "\n#### %s\n\n", title)
+			if body := strings.TrimSpace(strings.ReplaceAll(release.Body, "\r\n", "\n")); body != "" {
+				md.WriteString(body + "\n")
+			} else {
+				md.WriteString("_No release notes._\n")
+			}
+		}
+		md.WriteString("\n</details>\n")
+	case b.Commits != nil && len(b.Commits.Commits) > 0:
+		md.WriteString("\n")
+		commits := b.Commits.Commits
+		// Newest first, like the release notes
+		for i := len(commits) - 1; i >= 0 && i >= len(commits)-maxSummaryCommits; i-- {
+			title, _, _ := strings.Cut(commits[i].Commit.Message, "\n")
+			fmt.Fprintf(&md, "- %s %s\n", shortCommit(commits[i].SHA), title)
+		}
+		total := b.Commits.TotalCommits
+		if total < len(commits) {
+			total = len(commits)
+		}
+		if total > maxSummaryCommits {
+			fmt.Fprintf(&md, "- … and %d more\n", total-maxSummaryCommits)
+		}
+	}
+	return md.String()
+}
+
+// SummaryMarkdown describes all bumps of a manifest update.
+func SummaryMarkdown(bumps []*Bump) string {
+	if len(bumps) == 0 {
+		return "No services were updated.\n"
+	}
+	var sections []string
+	for _, bump := range bumps {
+		sections = append(sections, bump.Markdown())
+	}
+	return "## Updated services\n\n" + strings.Join(sections, "\n")
+}
+
+// WriteSummary fetches the changes of the bumps and writes their
+// summary to a file, or stdout for "-".
+func WriteSummary(path string, bumps []*Bump) error {
+	for _, bump := range bumps {
+		bump.FetchChanges()
+		if bump.Err != nil {
+			glog.Warningf("couldn't fetch changes of %s: %s", bump.Service, bump.Err)
+		}
+	}
+	summary := SummaryMarkdown(bumps)
+	if path == "-" {
+		_, err := os.Stdout.WriteString(summary)
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(summary), 0644)
+}
+
+// serviceRepository is the github repository the releases or commits
+// of a service come from.
+func serviceRepository(service *types.Service) string {
+	if service.Strategy.Download != "bucket" {
+		return service.Strategy.Project
+	}
+	if service.Strategy.Repository != "" {
+		return service.Strategy.Repository
+	}
+	return "livepeer/" + service.Strategy.Project
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,60 @@
+package manifest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBumpMarkdownCommits(t *testing.T) {
+	service := &types.Service{
+		Name:     "catalyst-api",
+		Release:  "main",
+		Strategy: &types.DownloadStrategy{Download: "bucket", Project: "catalyst-api", Commit: "def1234567890"},
+	}
+	bump := newBump(service, "main", "abc1234567890")
+	require.Equal(t, "livepeer/catalyst-api", bump.Repository)
+	bump.Commits = &types.CompareInformation{TotalCommits: 2}
+	for _, commit := range [][2]string{{"aaa1111111", "Fix segmenting\n\nLonger description"}, {"def1234567890", "Add clipping"}} {
+		information := &types.CommitInformation{SHA: commit[0]}
+		information.Commit.Message = commit[1]
+		bump.Commits.Commits = append(bump.Commits.Commits, information)
+	}
+
+	require.Equal(t, "### catalyst-api\n\n"+
+		"`main` abc1234 → `main` def1234 · [compare](https://github.com/livepeer/catalyst-api/compare/abc1234...def1234)\n\n"+
+		"- def1234 Add clipping\n"+
+		"- aaa1111 Fix segmenting\n", bump.Markdown())
+}
+
+func TestBumpMarkdownReleases(t *testing.T) {
+	service := &types.Service{
+		Name:     "go-livepeer",
+		Release:  "v0.5.38",
+		Strategy: &types.DownloadStrategy{Download: "github", Project: "livepeer/go-livepeer"},
+	}
+	bump := newBump(service, "v0.5.37", "")
+	bump.Releases = []*types.TagInformation{
+		{TagName: "v0.5.38", HTMLURL: "https://github.com/livepeer/go-livepeer/releases/tag/v0.5.38", Body: "- Faster transcoding\r\n"},
+	}
+	require.Equal(t, "### go-livepeer\n\n"+
+		"v0.5.37 → v0.5.38 · [compare](https://github.com/livepeer/go-livepeer/compare/v0.5.37...v0.5.38)\n\n"+
+		"<details>\n<summary>Release notes</summary>\n\n"+
+		"#### [v0.5.38](https://github.com/livepeer/go-livepeer/releases/tag/v0.5.38)\n\n"+
+		"- Faster transcoding\n\n"+
+		"</details>\n", bump.Markdown())
+
+	bump.Releases, bump.Err = nil, errors.New("HTTP 403")
+	require.Contains(t, bump.Markdown(), "_Couldn't fetch the changes: HTTP 403_")
+}
+
+func TestSummaryMarkdown(t *testing.T) {
+	require.Equal(t, "No services were updated.\n", SummaryMarkdown(nil))
+	bumps := []*Bump{
+		{Service: "a", Repository: "livepeer/a", OldRelease: "main", NewRelease: "main"},
+		{Service: "b", Repository: "livepeer/b", OldRelease: "main", NewRelease: "main"},
+	}
+	require.Equal(t, "## Updated services\n\n### a\n\n`main` → `main`\n\n### b\n\n`main` → `main`\n", SummaryMarkdown(bumps))
+}
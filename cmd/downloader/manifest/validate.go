@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/livepeer/catalyst/cmd/downloader/utils"
+	glog "github.com/magicsong/color-glog"
+)
+
+var ErrInvalidManifest = errors.New("manifest has errors")
+
+// Validate prints every schema violation and lint warning found in the
+// manifest, and fails if any of them is an error.
+func Validate(cliFlags types.CliFlags) error {
+	data, format, err := utils.ReadManifestFormat(cliFlags.ManifestFile, cliFlags.ManifestURL)
+	if err != nil {
+		return err
+	}
+	issues := schema.Validate(data)
+	for _, issue := range issues {
+		fmt.Println(utils.IssueString(cliFlags.ManifestFile, format, issue))
+	}
+	if schema.HasErrors(issues) {
+		return ErrInvalidManifest
+	}
+	glog.Infof("%s is valid", cliFlags.ManifestFile)
+	return nil
+}
+
+// PrintSchema writes the JSON Schema of the latest manifest version to
+// stdout.
+func PrintSchema(cliFlags types.CliFlags) error {
+	_, err := fmt.Printf("%s", schema.Schemas[schema.LatestVersion])
+	return err
+}
+
+// Migrate converts the manifest to the latest schema version and
+// writes it to stdout.
+func Migrate(cliFlags types.CliFlags) error {
+	m, err := utils.ParseYamlManifest(cliFlags.ManifestFile, cliFlags.ManifestURL)
+	if err != nil {
+		return err
+	}
+	if m.Version == schema.LatestVersion {
+		glog.Infof("%s already uses version %s", cliFlags.ManifestFile, schema.LatestVersion)
+	}
+	m.Version = schema.LatestVersion
+	data, err := EncodeYamlManifest(m)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -0,0 +1,277 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	glog "github.com/magicsong/color-glog"
+)
+
+const (
+	// Prefix of the executables that credential helpers are looked up as
+	helperPrefix = "catalyst-credential-"
+	// Longest a credential helper may take to answer
+	helperTimeout = 30 * time.Second
+)
+
+// credential authenticates the requests to one host. Its secret is
+// resolved on first use and never logged.
+type credential struct {
+	host string
+	// bearer, basic, netrc or helper
+	kind string
+	args []string
+
+	once          sync.Once
+	authorization string
+	err           error
+}
+
+// parseCredential reads a `<host>=<kind>[:<args>]` spec, where kind is
+//
+//	bearer:<token>
+//	basic:<user>:<password>
+//	netrc
+//	helper:<name>
+//
+// Tokens, users and passwords starting with $ are read from the
+// environment variable of that name.
+func parseCredential(spec string) (*credential, error) {
+	host, method, ok := strings.Cut(spec, "=")
+	if !ok || host == "" {
+		return nil, fmt.Errorf("invalid credential for %q, expected <host>=<method>", credentialHost(spec))
+	}
+	parts := strings.SplitN(method, ":", 3)
+	c := &credential{host: host, kind: parts[0], args: parts[1:]}
+	arguments := map[string]int{"bearer": 1, "basic": 2, "netrc": 0, "helper": 1}
+	count, known := arguments[c.kind]
+	switch {
+	case !known:
+		return nil, fmt.Errorf("unknown credential method %q for %s, expected bearer, basic, netrc or helper", c.kind, host)
+	case c.kind == "basic" && len(c.args) == 1:
+		// Passwords may contain colons, SplitN keeps them in the last part
+		return nil, fmt.Errorf("basic credential for %s needs a user and a password", host)
+	case len(c.args) != count:
+		return nil, fmt.Errorf("%s credential for %s takes %d arguments", c.kind, host, count)
+	}
+	return c, nil
+}
+
+// credentialHost keeps error messages about a spec free of its secret.
+func credentialHost(spec string) string {
+	host, _, _ := strings.Cut(spec, "=")
+	return host
+}
+
+func (c *credential) matches(u *url.URL) bool {
+	return strings.EqualFold(c.host, u.Host) || strings.EqualFold(c.host, u.Hostname())
+}
+
+// header returns the Authorization header to send, resolving the secret
+// the first time.
+func (c *credential) header(u *url.URL) (string, error) {
+	c.once.Do(func() {
+		c.authorization, c.err = c.resolve(u)
+		if c.err != nil {
+			c.err = fmt.Errorf("%s credential for %s: %w", c.kind, c.host, c.err)
+		}
+	})
+	return c.authorization, c.err
+}
+
+func (c *credential) resolve(u *url.URL) (string, error) {
+	switch c.kind {
+	case "bearer":
+		token, err := secret(c.args[0])
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	case "basic":
+		user, err := secret(c.args[0])
+		if err != nil {
+			return "", err
+		}
+		password, err := secret(c.args[1])
+		if err != nil {
+			return "", err
+		}
+		return basicAuth(user, password), nil
+	case "netrc":
+		login, password, err := netrcLookup(netrcPath(), u.Hostname())
+		if err != nil {
+			return "", err
+		}
+		return basicAuth(login, password), nil
+	}
+	return runHelper(c.args[0], u)
+}
+
+// secret expands a $VARIABLE, other values are taken literally.
+func secret(value string) (string, error) {
+	if !strings.HasPrefix(value, "$") {
+		return value, nil
+	}
+	expanded := os.Getenv(value[1:])
+	if expanded == "" {
+		return "", fmt.Errorf("environment variable %s is empty", value[1:])
+	}
+	return expanded, nil
+}
+
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcLookup returns the login and password of a machine in a .netrc
+// file, or of its default entry.
+func netrcLookup(path, host string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	type entry struct{ login, password string }
+	var found, fallback *entry
+	var current *entry
+	fields := strings.Fields(string(data))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			current = nil
+			if i+1 < len(fields) {
+				i++
+				if strings.EqualFold(fields[i], host) && found == nil {
+					found = &entry{}
+					current = found
+				}
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &entry{}
+				current = fallback
+			}
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				break
+			}
+			i++
+			if current == nil {
+				continue
+			}
+			if fields[i-1] == "login" {
+				current.login = fields[i]
+			} else if fields[i-1] == "password" {
+				current.password = fields[i]
+			}
+		case "macdef":
+			// Macros run until an empty line, which Fields loses. They
+			// only make sense for ftp and don't hold credentials.
+			current = nil
+		}
+	}
+	if found == nil {
+		found = fallback
+	}
+	if found == nil || found.login == "" {
+		return "", "", fmt.Errorf("no login for %s in %s", host, path)
+	}
+	return found.login, found.password, nil
+}
+
+// runHelper asks `catalyst-credential-<name> get` for the credential of
+// a host, like git credential helpers. The request is written to its
+// stdin and the answer read from its stdout, as key=value lines:
+//
+//	protocol=https
+//	host=build.example.com
+//
+// The helper answers with either `token=<token>`, sent as a bearer
+// token, or `username=<user>` and `password=<password>`.
+func runHelper(name string, u *url.URL) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid helper name %q", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), helperTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, helperPrefix+name, "get")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\n\n", u.Scheme, u.Host))
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	glog.V(6).Infof("asking %s%s for the credential of %s", helperPrefix, name, u.Host)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("helper %s%s failed: %w", helperPrefix, name, err)
+	}
+	answer := map[string]string{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			answer[key] = value
+		}
+	}
+	switch {
+	case answer["token"] != "":
+		return "Bearer " + answer["token"], nil
+	case answer["username"] != "":
+		return basicAuth(answer["username"], answer["password"]), nil
+	}
+	return "", errors.New("helper returned neither a token nor a username")
+}
+
+// authTransport adds the Authorization header of the host's credential
+// to requests that don't have one yet.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials []*credential
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	for _, c := range t.credentials {
+		if !c.matches(req.URL) {
+			continue
+		}
+		// Never send secrets in plaintext to hosts that aren't allowed
+		if err := CheckURL(req.URL.String()); err != nil {
+			return nil, err
+		}
+		header, err := c.header(req.URL)
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", header)
+		break
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,134 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// authorizationOf returns the Authorization header a request to server
+// is sent with.
+func authorizationOf(t *testing.T, server *httptest.Server) (string, error) {
+	var received string
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization")
+	})
+	resp, err := Get(server.URL + "/mistserver/catalyst.json")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return received, nil
+}
+
+func TestParseCredential(t *testing.T) {
+	for _, spec := range []string{"builds.internal=bearer:$TOKEN", "builds.internal=basic:ci:pass:with:colons", "builds.internal=netrc", "builds.internal=helper:vault"} {
+		_, err := parseCredential(spec)
+		require.NoError(t, err, spec)
+	}
+	_, err := parseCredential("builds.internal=token:s3cr3t")
+	require.EqualError(t, err, `unknown credential method "token" for builds.internal, expected bearer, basic, netrc or helper`)
+	_, err = parseCredential("builds.internal=basic:s3cr3t")
+	require.EqualError(t, err, "basic credential for builds.internal needs a user and a password")
+	_, err = parseCredential("builds.internal=netrc:s3cr3t")
+	require.EqualError(t, err, "netrc credential for builds.internal takes 0 arguments")
+	_, err = parseCredential("s3cr3t")
+	require.EqualError(t, err, `invalid credential for "s3cr3t", expected <host>=<method>`)
+}
+
+func TestBearerAndBasicCredentials(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	host := "127.0.0.1"
+	t.Setenv("CATALYST_TEST_TOKEN", "s3cr3t")
+
+	configure(t, Options{AllowHTTP: []string{host}, Credentials: []string{host + "=bearer:$CATALYST_TEST_TOKEN"}})
+	header, err := authorizationOf(t, server)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer s3cr3t", header)
+
+	configure(t, Options{AllowHTTP: []string{host}, Credentials: []string{host + "=basic:ci:$CATALYST_TEST_TOKEN"}})
+	header, err = authorizationOf(t, server)
+	require.NoError(t, err)
+	require.Equal(t, basicAuth("ci", "s3cr3t"), header)
+
+	configure(t, Options{AllowHTTP: []string{host}, Credentials: []string{"other.internal=bearer:$CATALYST_TEST_TOKEN"}})
+	header, err = authorizationOf(t, server)
+	require.NoError(t, err)
+	require.Empty(t, header, "credentials only go to their host")
+
+	configure(t, Options{AllowHTTP: []string{host}, Credentials: []string{host + "=bearer:$CATALYST_TEST_UNSET"}})
+	_, err = authorizationOf(t, server)
+	require.ErrorContains(t, err, "bearer credential for 127.0.0.1: environment variable CATALYST_TEST_UNSET is empty")
+}
+
+func TestNetrcCredential(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(netrc, []byte(`machine github.com login octocat password ghp_other
+machine 127.0.0.1
+  login ci
+  password s3cr3t
+default login anonymous password guest
+`), 0600))
+	t.Setenv("NETRC", netrc)
+
+	login, password, err := netrcLookup(netrc, "127.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci", "s3cr3t"}, []string{login, password})
+	login, password, err = netrcLookup(netrc, "builds.internal")
+	require.NoError(t, err)
+	require.Equal(t, []string{"anonymous", "guest"}, []string{login, password})
+
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	configure(t, Options{AllowHTTP: []string{"127.0.0.1"}, Credentials: []string{"127.0.0.1=netrc"}})
+	header, err := authorizationOf(t, server)
+	require.NoError(t, err)
+	require.Equal(t, basicAuth("ci", "s3cr3t"), header)
+}
+
+func TestHelperCredential(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper is a shell script")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "catalyst-credential-test"), []byte(`#!/bin/sh
+test "$1" = get || exit 1
+while read -r line && [ -n "$line" ]; do
+  case "$line" in host=*) host="${line#host=}" ;; esac
+done
+echo "token=token-for-$host"
+`), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	host := server.Listener.Addr().String()
+	configure(t, Options{AllowHTTP: []string{"127.0.0.1"}, Credentials: []string{host + "=helper:test"}})
+	header, err := authorizationOf(t, server)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-for-"+host, header)
+
+	_, err = runHelper("missing", &url.URL{Scheme: "https", Host: "builds.internal"})
+	require.ErrorContains(t, err, "helper catalyst-credential-missing failed")
+}
+
+func TestCredentialsNeedHTTPS(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	t.Setenv("CATALYST_TEST_TOKEN", "s3cr3t")
+	configure(t, Options{Credentials: []string{"127.0.0.1=bearer:$CATALYST_TEST_TOKEN"}})
+	// Like the github client, which doesn't go through Get
+	resp, err := Client.Get(server.URL)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	require.ErrorContains(t, err, "over plaintext http")
+	require.NotContains(t, err.Error(), "s3cr3t")
+}
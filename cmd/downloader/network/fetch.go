@@ -0,0 +1,143 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrNotFound is returned by Fetch for objects that don't exist.
+var ErrNotFound = errors.New("not found")
+
+// S3Endpoint is the S3 compatible API that s3:// URLs are fetched from,
+// e.g. a local MinIO.
+var S3Endpoint = "https://s3.amazonaws.com"
+
+// Object is a remote file, along with what identifies its version.
+type Object struct {
+	Data         []byte
+	ETag         string
+	LastModified string
+}
+
+// Fetch gets an http(s):// or s3://<bucket>/<key> URL. If the caller
+// has a copy of the object that is still current, that copy is returned
+// instead.
+func Fetch(rawURL string, cached *Object) (*Object, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == "s3" {
+		return fetchS3(parsed, cached)
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached != nil && cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+	resp, err := Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", Redact(rawURL), ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("HTTP %d while fetching %s", resp.StatusCode, Redact(rawURL))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Data: data, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// fetchS3 gets an object from S3Endpoint, with the credentials of the
+// AWS_* environment variables or ~/.aws/credentials, if any.
+func fetchS3(u *url.URL, cached *Object) (*Object, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %s, expected s3://<bucket>/<key>", Redact(u.String()))
+	}
+	client, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if cached != nil && cached.ETag != "" {
+		info, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+		if err != nil {
+			return nil, s3Error(u, err)
+		}
+		if info.ETag == cached.ETag {
+			return cached, nil
+		}
+	}
+	object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error(u, err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, s3Error(u, err)
+	}
+	info, err := object.Stat()
+	if err != nil {
+		return nil, s3Error(u, err)
+	}
+	return &Object{Data: data, ETag: info.ETag}, nil
+}
+
+func s3Client() (*minio.Client, error) {
+	endpoint, err := url.Parse(S3Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	// MinIO without TLS needs to be allowed like any http:// host
+	if err := CheckURL(endpoint.String()); err != nil {
+		return nil, err
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return minio.New(endpoint.Host, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.EnvMinio{},
+		}),
+		Secure:    endpoint.Scheme == "https",
+		Transport: Client.Transport,
+		Region:    region,
+	})
+}
+
+func s3Error(u *url.URL, err error) error {
+	response := minio.ToErrorResponse(err)
+	if response.StatusCode == http.StatusNotFound || response.Code == "NoSuchKey" {
+		return fmt.Errorf("%s: %w", Redact(u.String()), ErrNotFound)
+	}
+	if response.StatusCode != 0 {
+		return fmt.Errorf("HTTP %d while fetching %s: %s", response.StatusCode, Redact(u.String()), response.Code)
+	}
+	return fmt.Errorf("can't fetch %s: %w", Redact(u.String()), err)
+}
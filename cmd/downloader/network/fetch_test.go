@@ -0,0 +1,87 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetch(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing.yaml":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			fetches++
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("version: \"3.0\"\n"))
+		}
+	}))
+	defer server.Close()
+
+	_, err := Fetch(server.URL+"/manifest.yaml", nil)
+	require.ErrorContains(t, err, "over plaintext http")
+	configure(t, Options{AllowHTTP: []string{"127.0.0.1"}})
+
+	object, err := Fetch(server.URL+"/manifest.yaml", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"v1"`, object.ETag)
+	cached, err := Fetch(server.URL+"/manifest.yaml", object)
+	require.NoError(t, err)
+	require.Same(t, object, cached)
+	require.Equal(t, 1, fetches)
+
+	_, err = Fetch(server.URL+"/missing.yaml", nil)
+	require.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestFetchS3(t *testing.T) {
+	// Path style requests of a MinIO
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/releases/manifest.yaml" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "15")
+		w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 00:00:00 GMT")
+		if r.Method == http.MethodHead {
+			return
+		}
+		gets++
+		w.Write([]byte("version: \"3.0\"\n"))
+	}))
+	defer server.Close()
+	defer func(endpoint string) { S3Endpoint = endpoint }(S3Endpoint)
+	S3Endpoint = server.URL
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "minioadmin")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minioadmin")
+
+	_, err := Fetch("s3://releases/manifest.yaml", nil)
+	require.ErrorContains(t, err, "over plaintext http")
+	configure(t, Options{AllowHTTP: []string{"127.0.0.1"}})
+
+	object, err := Fetch("s3://releases/manifest.yaml", nil)
+	require.NoError(t, err)
+	require.Equal(t, "version: \"3.0\"\n", string(object.Data))
+	require.Equal(t, "v1", object.ETag)
+	cached, err := Fetch("s3://releases/manifest.yaml", object)
+	require.NoError(t, err)
+	require.Same(t, object, cached)
+	require.Equal(t, 1, gets)
+
+	_, err = Fetch("s3://releases/missing.yaml", nil)
+	require.True(t, errors.Is(err, ErrNotFound), err)
+	_, err = Fetch("s3://releases", nil)
+	require.EqualError(t, err, "invalid S3 URL s3://releases, expected s3://<bucket>/<key>")
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	glog "github.com/magicsong/color-glog"
+)
+
+// Options configure the HTTP client that manifests, build information,
+// API calls and artifacts are fetched with.
+type Options struct {
+	// PEM file of certificates to trust on top of the system ones,
+	// e.g. of a private CA
+	CABundle string
+	// PEM certificate and key to present to servers asking for one,
+	// e.g. private mirrors
+	ClientCert string
+	ClientKey  string
+	// Hosts that plaintext http:// is allowed from, with an optional
+	// port
+	AllowHTTP []string
+	// Per host credentials, see parseCredential
+	Credentials []string
+}
+
+// Client is shared by every request of the downloader. Like the default
+// client, it goes through the proxy set in HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY.
+var Client = &http.Client{CheckRedirect: checkRedirect}
+
+var allowHTTP []string
+
+// Configure replaces Client with one using the CA bundle, client
+// certificate and credentials of opts, and sets the hosts http:// is
+// allowed from.
+func Configure(opts Options) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			glog.Warningf("can't load the system certificates, only trusting %s: %s", opts.CABundle, err)
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return errors.New("a client certificate needs both a certificate and a key")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	auth := &authTransport{base: transport}
+	for _, spec := range opts.Credentials {
+		c, err := parseCredential(spec)
+		if err != nil {
+			return err
+		}
+		auth.credentials = append(auth.credentials, c)
+	}
+	Client = &http.Client{Transport: auth, CheckRedirect: checkRedirect}
+	allowHTTP = opts.AllowHTTP
+	return nil
+}
+
+// CheckURL refuses URLs that aren't https, apart from http:// ones of
+// the hosts it's allowed from.
+func CheckURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch parsed.Scheme {
+	case "https":
+		return nil
+	case "http":
+		for _, host := range allowHTTP {
+			if strings.EqualFold(host, parsed.Host) || strings.EqualFold(host, parsed.Hostname()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("refusing to fetch %s over plaintext http, allow it with -allow-http=%s", redact(parsed), parsed.Hostname())
+	}
+	return fmt.Errorf("refusing to fetch %s, only https is supported", redact(parsed))
+}
+
+// Get fetches a URL that passes CheckURL, along with every redirect.
+func Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Do(req)
+}
+
+// Do sends a request to a URL that passes CheckURL.
+func Do(req *http.Request) (*http.Response, error) {
+	if err := CheckURL(req.URL.String()); err != nil {
+		return nil, err
+	}
+	return Client.Do(req)
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	// Redirects mustn't downgrade to plaintext either
+	return CheckURL(req.URL.String())
+}
+
+// Redact drops the credentials of a URL, so it can be logged.
+func Redact(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return redact(parsed)
+}
+
+func redact(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	copied := *u
+	copied.User = url.User("xxxxx")
+	return copied.String()
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+)
+
+// ToV4 converts a manifest into the version 4 schema. Services read from
+// version 3 manifests get explicit per-platform artifacts, named the way
+// they were in version 3. Only bucket services that name nothing at all
+// keep taking the names from the build information.
+func ToV4(m *types.BoxManifest) (*types.BoxManifestV4, error) {
+	v4 := &types.BoxManifestV4{
+		Version: "4.0",
+		Release: m.Release,
+		Vars:    m.Vars,
+		Groups:  m.Groups,
+	}
+	for _, service := range m.Box {
+		converted := &types.ServiceV4{
+			Name:               service.Name,
+			Release:            service.Release,
+			PinnedRelease:      service.PinnedRelease,
+			AllowPrerelease:    service.AllowPrerelease,
+			Strategy:           &types.StrategyV4{},
+			Skip:               service.Skip,
+			SkipGPG:            service.SkipGPG,
+			SkipChecksum:       service.SkipChecksum,
+			SkipManifestUpdate: service.SkipManifestUpdate,
+			PreInstall:         service.PreInstall,
+			PostInstall:        service.PostInstall,
+			Check:              service.Check,
+			Tags:               service.Tags,
+			Requires:           service.Requires,
+		}
+		if service.Strategy != nil {
+			converted.Commit = service.Strategy.Commit
+			if service.Strategy.Download == "bucket" {
+				converted.Strategy.Bucket = &types.BucketStrategy{Project: service.Strategy.Project, Repository: service.Strategy.Repository, BaseURL: service.Strategy.BaseURL}
+			} else {
+				converted.Strategy.GitHub = &types.GitHubStrategy{Project: service.Strategy.Project, AssetPattern: service.Strategy.AssetPattern, BaseURL: service.Strategy.BaseURL}
+			}
+		}
+		artifacts, err := artifactsV4(service)
+		if err != nil {
+			return nil, err
+		}
+		converted.Artifacts = artifacts
+		converted.Artifact = service.Artifact
+		v4.Services = append(v4.Services, converted)
+	}
+	return v4, nil
+}
+
+func artifactsV4(service *types.Service) (map[string]*types.Artifact, error) {
+	if service.Artifacts != nil || service.Artifact != nil {
+		return service.Artifacts, nil
+	}
+	// Bucket services without names take them from the build information
+	named := service.SrcFilenames != nil || service.SrcFilename != "" || service.Binary != "" || service.ArchivePath != "" || service.OutputPath != ""
+	if !named && service.Strategy != nil && service.Strategy.Download == "bucket" {
+		return nil, nil
+	}
+	// Default names and templates cover every supported platform
+	platforms := SupportedPlatforms
+	if service.SrcFilenames != nil && service.SrcFilename == "" {
+		platforms = nil
+		for platArch := range service.SrcFilenames {
+			platforms = append(platforms, platArch)
+		}
+		sort.Strings(platforms)
+	}
+	artifacts := map[string]*types.Artifact{}
+	for _, platArch := range platforms {
+		platform, architecture, ok := strings.Cut(platArch, "-")
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid platform %q in srcFilenames", service.Name, platArch)
+		}
+		// Keep variables, so that e.g. bumping the release still updates the file names
+		artifact, err := service.TemplateFor(platform, architecture)
+		if err != nil {
+			return nil, err
+		}
+		artifacts[platArch] = artifact
+	}
+	return artifacts, nil
+}
+
+// FromV4 converts a version 4 manifest into the BoxManifest the rest
+// of the downloader works with. The version is kept, so that writing
+// the manifest back produces the version 4 schema again.
+func FromV4(v4 *types.BoxManifestV4) *types.BoxManifest {
+	m := &types.BoxManifest{
+		Version: v4.Version,
+		Release: v4.Release,
+		Vars:    v4.Vars,
+		Groups:  v4.Groups,
+	}
+	for _, service := range v4.Services {
+		converted := &types.Service{
+			Name:               service.Name,
+			Release:            service.Release,
+			PinnedRelease:      service.PinnedRelease,
+			AllowPrerelease:    service.AllowPrerelease,
+			Strategy:           &types.DownloadStrategy{Commit: service.Commit},
+			Artifacts:          service.Artifacts,
+			Artifact:           service.Artifact,
+			Skip:               service.Skip,
+			SkipGPG:            service.SkipGPG,
+			SkipChecksum:       service.SkipChecksum,
+			SkipManifestUpdate: service.SkipManifestUpdate,
+			PreInstall:         service.PreInstall,
+			PostInstall:        service.PostInstall,
+			Check:              service.Check,
+			Tags:               service.Tags,
+			Requires:           service.Requires,
+		}
+		if service.Strategy != nil && service.Strategy.Bucket != nil {
+			converted.Strategy.Download = "bucket"
+			converted.Strategy.Project = service.Strategy.Bucket.Project
+			converted.Strategy.Repository = service.Strategy.Bucket.Repository
+			converted.Strategy.BaseURL = service.Strategy.Bucket.BaseURL
+		} else if service.Strategy != nil && service.Strategy.GitHub != nil {
+			converted.Strategy.Download = "github"
+			converted.Strategy.Project = service.Strategy.GitHub.Project
+			converted.Strategy.AssetPattern = service.Strategy.GitHub.AssetPattern
+			converted.Strategy.BaseURL = service.Strategy.GitHub.BaseURL
+		}
+		m.Box = append(m.Box, converted)
+	}
+	m.PropagateVars()
+	return m
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/livepeer/catalyst/cmd/downloader/types"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateRepositoryManifest(t *testing.T) {
+	data, err := os.ReadFile("../../../manifest.yaml")
+	require.NoError(t, err)
+	var v3 types.BoxManifest
+	require.NoError(t, yaml.Unmarshal(data, &v3))
+
+	converted, err := ToV4(&v3)
+	require.NoError(t, err)
+	data, err = yaml.Marshal(converted)
+	require.NoError(t, err)
+	issues := Validate(data)
+	require.False(t, HasErrors(issues), "%v", issues)
+
+	var v4 types.BoxManifestV4
+	require.NoError(t, yaml.Unmarshal(data, &v4))
+	migrated := FromV4(&v4)
+	require.Equal(t, "4.0", migrated.Version)
+	require.Equal(t, v3.Release, migrated.Release)
+	require.Len(t, migrated.Box, len(v3.Box))
+
+	for i, before := range v3.Box {
+		after := migrated.Box[i]
+		require.Equal(t, before.Name, after.Name)
+		require.Equal(t, before.Release, after.Release)
+		require.Equal(t, before.Strategy.Commit, after.Strategy.Commit)
+		require.Equal(t, before.Strategy.Project, after.Strategy.Project)
+		require.Equal(t, before.SkipGPG, after.SkipGPG)
+		require.Equal(t, before.SkipChecksum, after.SkipChecksum)
+		require.Equal(t, before.SkipManifestUpdate, after.SkipManifestUpdate)
+		for _, platArch := range SupportedPlatforms {
+			platform, architecture := platArch[:len(platArch)-6], platArch[len(platArch)-5:]
+			expected, expectedErr := before.ArtifactFor(platform, architecture)
+			actual, actualErr := after.ArtifactFor(platform, architecture)
+			if expectedErr != nil {
+				require.Error(t, actualErr, "%s %s", before.Name, platArch)
+				continue
+			}
+			require.NoError(t, actualErr, "%s %s", before.Name, platArch)
+			require.Equal(t, expected, actual, "%s %s", before.Name, platArch)
+		}
+	}
+}
+
+func TestMigrateKeepsDefaultArtifactNames(t *testing.T) {
+	v3 := &types.BoxManifest{
+		Version: "3.0",
+		Box: []*types.Service{{
+			Name:     "task-runner",
+			Release:  "main",
+			Strategy: &types.DownloadStrategy{Download: "bucket", Project: "task-runner"},
+		}},
+	}
+	converted, err := ToV4(v3)
+	require.NoError(t, err)
+	require.Nil(t, converted.Services[0].Artifacts)
+	require.NotNil(t, converted.Services[0].Strategy.Bucket)
+	require.Nil(t, converted.Services[0].Strategy.GitHub)
+
+	artifact, err := FromV4(converted).Box[0].ArtifactFor("linux", "arm64")
+	require.NoError(t, err)
+	require.Equal(t, "livepeer-task-runner-linux-arm64.tar.gz", artifact.File)
+}
+
+func TestMigrateDefaultArtifactNames(t *testing.T) {
+	v3 := &types.BoxManifest{
+		Version: "3.0",
+		Box: []*types.Service{{
+			Name:        "livepeer",
+			Release:     "master",
+			Binary:      "livepeer",
+			ArchivePath: "livepeer",
+			OutputPath:  "lp",
+			Strategy:    &types.DownloadStrategy{Download: "bucket", Project: "go-livepeer"},
+		}},
+	}
+	converted, err := ToV4(v3)
+	require.NoError(t, err)
+	require.Len(t, converted.Services[0].Artifacts, len(SupportedPlatforms))
+	require.Equal(t, "livepeer-windows-arm64.zip", converted.Services[0].Artifacts["windows-arm64"].File)
+	data, err := yaml.Marshal(converted)
+	require.NoError(t, err)
+	require.False(t, HasErrors(Validate(data)))
+
+	for _, platArch := range SupportedPlatforms {
+		platform, architecture, _ := strings.Cut(platArch, "-")
+		expected, err := v3.Box[0].ArtifactFor(platform, architecture)
+		require.NoError(t, err)
+		actual, err := FromV4(converted).Box[0].ArtifactFor(platform, architecture)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, platArch)
+	}
+}
@@ -0,0 +1,253 @@
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const LatestVersion = "4.0"
+
+var (
+	//go:embed manifest.v3.schema.json
+	v3JSON []byte
+	//go:embed manifest.v4.schema.json
+	v4JSON []byte
+)
+
+// Schemas are the published JSON Schemas of each manifest version.
+// Editors can use them for completion, the downloader uses them to
+// validate manifests.
+var Schemas = map[string][]byte{
+	"3.0": v3JSON,
+	"4.0": v4JSON,
+}
+
+// Subset of JSON Schema draft-07 that the manifest schema relies on.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Enum                 []string               `json:"enum"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	PropertyNames        *jsonSchema            `json:"propertyNames"`
+	Items                *jsonSchema            `json:"items"`
+	MinLength            int                    `json:"minLength"`
+	MinItems             int                    `json:"minItems"`
+	Pattern              string                 `json:"pattern"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+}
+
+// additionalProperties is either `false` or a schema for the values
+// of undeclared keys.
+type additionalProperties struct {
+	Forbidden bool
+	Schema    *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.Forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(data, &a.Schema)
+}
+
+var roots = map[string]*jsonSchema{}
+
+func init() {
+	for version, data := range Schemas {
+		var root jsonSchema
+		if err := json.Unmarshal(data, &root); err != nil {
+			panic(fmt.Errorf("invalid manifest %s schema: %w", version, err))
+		}
+		roots[version] = &root
+	}
+}
+
+// resolve follows a local `#/definitions/<name>` reference.
+func (s *jsonSchema) resolve(root *jsonSchema) *jsonSchema {
+	if s.Ref == "" {
+		return s
+	}
+	return root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+}
+
+// checkNode validates a yaml node against a schema and appends any
+// violations to the issue list.
+func checkNode(root, s *jsonSchema, node *yaml.Node, path string, issues *[]Issue) {
+	s = s.resolve(root)
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	fail := func(format string, args ...interface{}) {
+		*issues = append(*issues, errorAt(node, "%s", describe(path, fmt.Sprintf(format, args...))))
+	}
+
+	if len(s.Enum) > 0 {
+		if node.Kind != yaml.ScalarNode || !contains(s.Enum, node.Value) {
+			fail("must be one of %s", strings.Join(quoteAll(s.Enum), ", "))
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			fail("must be a mapping")
+			return
+		}
+		checkMapping(root, s, node, path, issues)
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			fail("must be a list")
+			return
+		}
+		if len(node.Content) < s.MinItems {
+			fail("must have at least %d entries", s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range node.Content {
+				checkNode(root, s.Items, item, fmt.Sprintf("%s[%d]", path, i), issues)
+			}
+		}
+	case "string":
+		if node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+			fail("must be a string")
+			return
+		}
+		if len(node.Value) < s.MinLength {
+			fail("must not be empty")
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(node.Value) {
+			fail("%q doesn't match %s", node.Value, s.Pattern)
+		}
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			fail("must be true or false")
+		}
+	}
+}
+
+func checkMapping(root, s *jsonSchema, node *yaml.Node, path string, issues *[]Issue) {
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "<<" {
+			continue
+		}
+		seen[key.Value] = true
+		childPath := key.Value
+		if path != "" {
+			childPath = path + "." + key.Value
+		}
+		if s.PropertyNames != nil {
+			checkNode(root, s.PropertyNames, key, childPath, issues)
+		}
+		if property, ok := s.Properties[key.Value]; ok {
+			checkNode(root, property, value, childPath, issues)
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if s.AdditionalProperties.Forbidden {
+			message := fmt.Sprintf("unknown key %q", key.Value)
+			if suggestion := suggest(key.Value, s.Properties); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			*issues = append(*issues, errorAt(key, "%s", describe(path, message)))
+			continue
+		}
+		if s.AdditionalProperties.Schema != nil {
+			checkNode(root, s.AdditionalProperties.Schema, value, childPath, issues)
+		}
+	}
+	for _, required := range s.Required {
+		if !seen[required] {
+			*issues = append(*issues, errorAt(node, "%s", describe(path, fmt.Sprintf("missing required key %q", required))))
+		}
+	}
+}
+
+// describe prefixes a message with the dotted path it's about.
+func describe(path, message string) string {
+	if path == "" {
+		return message
+	}
+	return path + ": " + message
+}
+
+// suggest finds the declared key closest to a misspelt one.
+func suggest(key string, properties map[string]*jsonSchema) string {
+	var names []string
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		if d := distance(strings.ToLower(name), strings.ToLower(key)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein edit distance between two strings.
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func contains(list []string, item string) bool {
+	for _, element := range list {
+		if element == item {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(list []string) []string {
+	quoted := make([]string, len(list))
+	for i, item := range list {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	return quoted
+}
@@ -0,0 +1,434 @@
+package schema
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livepeer/catalyst/cmd/downloader/semver"
+	"gopkg.in/yaml.v3"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Platforms the downloader can install services for. Services with
+// `srcFilenames` should name an artifact for each of them.
+var SupportedPlatforms = []string{"darwin-amd64", "darwin-arm64", "linux-amd64", "linux-arm64", "windows-amd64", "windows-arm64"}
+
+// Variables every service defines, which `vars` can't override.
+var builtinVariables = map[string]bool{"release": true, "commit": true, "name": true, "platform": true, "arch": true, "ext": true}
+
+// Issue is a problem found in a manifest, pointing at the offending line.
+type Issue struct {
+	Line   int
+	Column int
+	// Key path of the offending node, e.g. box[0].strategy.commit, empty
+	// for the document as a whole
+	Path     string
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Severity, i.Message)
+}
+
+// HasErrors reports whether any issue is severe enough to refuse the manifest.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func errorAt(node *yaml.Node, format string, args ...interface{}) Issue {
+	return Issue{Line: node.Line, Column: node.Column, Severity: SeverityError, Message: fmt.Sprintf(format, args...)}
+}
+
+func warningAt(node *yaml.Node, format string, args ...interface{}) Issue {
+	return Issue{Line: node.Line, Column: node.Column, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validate checks a yaml manifest against the JSON Schema of its
+// version, then lints it for mistakes the schema can't express, such as
+// duplicate service names or two services extracting to the same file.
+func Validate(data []byte) []Issue {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return []Issue{{Line: yamlErrorLine(err), Severity: SeverityError, Message: err.Error()}}
+	}
+	if len(document.Content) == 0 {
+		return []Issue{{Line: 1, Column: 1, Severity: SeverityError, Message: "manifest is empty"}}
+	}
+	root := document.Content[0]
+	version := lookup(root, "version")
+	if version == nil {
+		return []Issue{errorAt(root, "missing required key %q", "version")}
+	}
+	rootSchema, ok := roots[version.Value]
+	if !ok {
+		return []Issue{errorAt(version, "invalid manifest version %q. Currently supported versions: %s", version.Value, strings.Join(Versions(), ", "))}
+	}
+	var issues []Issue
+	checkNode(rootSchema, rootSchema, root, "", &issues)
+	if !HasErrors(issues) {
+		issues = append(issues, lint(root, version.Value)...)
+	}
+	paths := map[[2]int]string{}
+	keyPaths(root, "", paths)
+	for i := range issues {
+		issues[i].Path = paths[[2]int{issues[i].Line, issues[i].Column}]
+	}
+	return issues
+}
+
+// keyPaths maps the line and column of every node under node to its
+// key path. Nodes starting where their parent does, like the first key
+// of a block mapping, keep the path of the parent.
+func keyPaths(node *yaml.Node, path string, paths map[[2]int]string) {
+	position := [2]int{node.Line, node.Column}
+	if _, ok := paths[position]; !ok {
+		paths[position] = path
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			keyPaths(node.Content[i], key, paths)
+			keyPaths(node.Content[i+1], key, paths)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			keyPaths(item, fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+	}
+}
+
+// Versions lists the supported manifest versions, oldest first.
+func Versions() []string {
+	var versions []string
+	for version := range Schemas {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// serviceNodes gives version independent access to the yaml nodes of
+// a service that the lints care about.
+type serviceNodes struct {
+	node    *yaml.Node
+	name    *yaml.Node
+	bucket  bool
+	skip    bool
+	release *yaml.Node
+	// Nodes naming the files extracted per platform
+	outputs map[string][]*yaml.Node
+	// srcFilenames or artifacts, keyed by platform
+	platforms *yaml.Node
+	// Release asset names the platforms are generated from
+	assetPattern *yaml.Node
+	baseURL      *yaml.Node
+}
+
+func v3Services(root *yaml.Node) ([]*serviceNodes, []Issue) {
+	var services []*serviceNodes
+	var issues []Issue
+	for _, service := range content(lookup(root, "box")) {
+		nodes := &serviceNodes{
+			node:      service,
+			name:      lookup(service, "name"),
+			release:   lookup(service, "release"),
+			skip:      isTrue(lookup(service, "skip")),
+			outputs:   map[string][]*yaml.Node{},
+			platforms: lookup(service, "srcFilenames"),
+		}
+		// Next to srcFilename, srcFilenames only lists exceptions
+		if lookup(service, "srcFilename") != nil {
+			nodes.platforms = nil
+		}
+		if download := lookup(lookup(service, "strategy"), "download"); download != nil && download.Value == "bucket" {
+			nodes.bucket = true
+		}
+		nodes.assetPattern = lookup(lookup(service, "strategy"), "assetPattern")
+		nodes.baseURL = lookup(lookup(service, "strategy"), "baseURL")
+		if output := firstLookup(service, "outputPath", "archivePath"); output != nil {
+			for _, platform := range SupportedPlatforms {
+				nodes.outputs[platform] = []*yaml.Node{output}
+			}
+		}
+		services = append(services, nodes)
+	}
+	return services, issues
+}
+
+func v4Services(root *yaml.Node) ([]*serviceNodes, []Issue) {
+	var services []*serviceNodes
+	var issues []Issue
+	for _, service := range content(lookup(root, "services")) {
+		nodes := &serviceNodes{
+			node:      service,
+			name:      lookup(service, "name"),
+			release:   lookup(service, "release"),
+			skip:      isTrue(lookup(service, "skip")),
+			outputs:   map[string][]*yaml.Node{},
+			platforms: lookup(service, "artifacts"),
+		}
+		strategy := lookup(service, "strategy")
+		if len(strategy.Content) != 2 {
+			issues = append(issues, errorAt(strategy, "%s: strategy needs exactly one of `bucket` or `github`", nodes.name.Value))
+		}
+		nodes.bucket = lookup(strategy, "bucket") != nil
+		nodes.assetPattern = lookup(lookup(strategy, "github"), "assetPattern")
+		nodes.baseURL = lookup(lookup(strategy, "bucket"), "baseURL")
+		if nodes.baseURL == nil {
+			nodes.baseURL = lookup(lookup(strategy, "github"), "baseURL")
+		}
+		artifacts := map[string]*yaml.Node{}
+		platforms := nodes.platforms
+		for i := 0; platforms != nil && i+1 < len(platforms.Content); i += 2 {
+			artifacts[platforms.Content[i].Value] = platforms.Content[i+1]
+		}
+		if artifact := lookup(service, "artifact"); artifact != nil {
+			if platforms != nil {
+				issues = append(issues, errorAt(artifact, "%s: use either `artifact` or `artifacts`", nodes.name.Value))
+			}
+			if nodes.assetPattern != nil {
+				issues = append(issues, errorAt(artifact, "%s: `assetPattern` generates `artifacts`, drop `artifact`", nodes.name.Value))
+			}
+			for _, platform := range SupportedPlatforms {
+				artifacts[platform] = artifact
+			}
+		}
+		for platform, artifact := range artifacts {
+			for _, rule := range content(lookup(artifact, "extract")) {
+				output := lookup(rule, "output")
+				if output == nil {
+					output = lookup(rule, "path")
+				}
+				if output != nil {
+					nodes.outputs[platform] = append(nodes.outputs[platform], output)
+				}
+			}
+		}
+		services = append(services, nodes)
+	}
+	return services, issues
+}
+
+func lint(root *yaml.Node, version string) []Issue {
+	var services []*serviceNodes
+	var issues []Issue
+	if version == "3.0" {
+		services, issues = v3Services(root)
+	} else {
+		services, issues = v4Services(root)
+	}
+
+	vars := lookup(root, "vars")
+	for i := 0; vars != nil && i+1 < len(vars.Content); i += 2 {
+		if builtinVariables[vars.Content[i].Value] {
+			issues = append(issues, errorAt(vars.Content[i], "variable %q is built in and can't be redefined", vars.Content[i].Value))
+		}
+	}
+
+	names := map[string]*yaml.Node{}
+	outputs := map[string]*yaml.Node{}
+	for _, service := range services {
+		name := service.name
+		if first, ok := names[name.Value]; ok {
+			issues = append(issues, errorAt(name, "duplicate service %q, first declared on line %d", name.Value, first.Line))
+		} else {
+			names[name.Value] = name
+		}
+
+		if service.bucket && (service.release == nil || service.release.Value == "") {
+			issues = append(issues, errorAt(service.node, "%s: bucket services need a branch name as `release`", name.Value))
+		} else if service.release != nil && semver.IsRange(service.release.Value) {
+			if service.bucket {
+				issues = append(issues, errorAt(service.release, "%s: bucket services track a branch, not a version range", name.Value))
+			} else if _, err := semver.ParseConstraint(service.release.Value); err != nil {
+				issues = append(issues, errorAt(service.release, "%s: %s", name.Value, err))
+			}
+		}
+
+		if pattern := service.assetPattern; pattern != nil {
+			if service.bucket {
+				issues = append(issues, errorAt(pattern, "%s: `assetPattern` only applies to github releases", name.Value))
+			} else if _, err := path.Match(pattern.Value, ""); err != nil {
+				issues = append(issues, errorAt(pattern, "%s: invalid asset pattern %q", name.Value, pattern.Value))
+			}
+		}
+
+		if baseURL := service.baseURL; baseURL != nil {
+			// Not quoting the value, it may be a secret
+			if parsed, err := url.Parse(baseURL.Value); err != nil {
+				issues = append(issues, errorAt(baseURL, "%s: `baseURL` isn't a valid URL", name.Value))
+			} else if parsed.User != nil {
+				issues = append(issues, errorAt(baseURL, "%s: `baseURL` must not hold credentials, pass them with -credential", name.Value))
+			}
+		}
+
+		if !service.skip {
+			reported := map[*yaml.Node]bool{}
+			for _, platform := range SupportedPlatforms {
+				for _, output := range service.outputs[platform] {
+					key := platform + "/" + path.Base(output.Value)
+					first, ok := outputs[key]
+					if !ok {
+						outputs[key] = output
+						continue
+					}
+					if !reported[first] {
+						reported[first] = true
+						issues = append(issues, errorAt(output, "%s: extracts to %q, which line %d already writes to", name.Value, output.Value, first.Line))
+					}
+				}
+			}
+		}
+
+		if service.platforms != nil {
+			var missing []string
+			for _, platform := range SupportedPlatforms {
+				if lookup(service.platforms, platform) == nil {
+					missing = append(missing, platform)
+				}
+			}
+			if len(missing) > 0 {
+				issues = append(issues, warningAt(service.platforms, "%s: no artifact for %s", name.Value, strings.Join(missing, ", ")))
+			}
+		}
+
+		for _, stage := range []string{"preInstall", "postInstall", "check"} {
+			hook := lookup(service.node, stage)
+			if hook == nil {
+				continue
+			}
+			if timeout := lookup(hook, "timeout"); timeout != nil {
+				if _, err := time.ParseDuration(timeout.Value); err != nil {
+					issues = append(issues, errorAt(timeout, "%s: invalid %s timeout: %s", name.Value, stage, err))
+				}
+			}
+			if expect := lookup(hook, "expect"); expect != nil {
+				if _, err := regexp.Compile(expect.Value); err != nil {
+					issues = append(issues, errorAt(expect, "%s: invalid %s expect pattern: %s", name.Value, stage, err))
+				}
+			}
+		}
+	}
+
+	tags := map[string]bool{}
+	buckets := map[string]bool{}
+	for _, service := range services {
+		for _, tag := range content(lookup(service.node, "tags")) {
+			tags[tag.Value] = true
+		}
+		if service.bucket {
+			buckets[service.name.Value] = true
+		}
+	}
+	for _, service := range services {
+		for _, requirement := range content(lookup(service.node, "requires")) {
+			required := lookup(requirement, "service")
+			if required == nil {
+				continue
+			}
+			if required.Value == service.name.Value {
+				issues = append(issues, errorAt(required, "%s: can't require itself", service.name.Value))
+			} else if names[required.Value] == nil {
+				issues = append(issues, errorAt(required, "%s: requires unknown service %q", service.name.Value, required.Value))
+			}
+			release := lookup(requirement, "release")
+			if release != nil && buckets[required.Value] {
+				// Their releases are branch names, which no version range matches
+				issues = append(issues, errorAt(release, "%s: %q is a bucket service tracking a branch, require `commits` of it instead of a `release`", service.name.Value, required.Value))
+			} else if release != nil {
+				if _, err := semver.ParseConstraint(release.Value); err != nil {
+					issues = append(issues, errorAt(release, "%s: %s", service.name.Value, err))
+				}
+			} else if lookup(requirement, "commits") == nil {
+				issues = append(issues, errorAt(requirement, "%s: requirement on %q needs `release` or `commits`", service.name.Value, required.Value))
+			}
+		}
+	}
+
+	groups := lookup(root, "groups")
+	for i := 0; groups != nil && i+1 < len(groups.Content); i += 2 {
+		group := groups.Content[i].Value
+		for _, member := range content(groups.Content[i+1]) {
+			if names[member.Value] == nil && !tags[member.Value] {
+				issues = append(issues, errorAt(member, "group %q: no service named or tagged %q", group, member.Value))
+			}
+		}
+	}
+	return issues
+}
+
+// content returns the entries of a yaml sequence, or nil.
+func content(sequence *yaml.Node) []*yaml.Node {
+	if sequence == nil || sequence.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return sequence.Content
+}
+
+func isTrue(node *yaml.Node) bool {
+	return node != nil && node.Value == "true"
+}
+
+// lookup returns the value of a key in a yaml mapping, or nil.
+// firstLookup returns the value of the first of keys that is set.
+func firstLookup(mapping *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		if value := lookup(mapping, key); value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil {
+		return nil
+	}
+	if mapping.Kind == yaml.AliasNode {
+		mapping = mapping.Alias
+	}
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			if value.Kind == yaml.AliasNode {
+				value = value.Alias
+			}
+			return value
+		}
+	}
+	return nil
+}
+
+var yamlErrorLineRegex = regexp.MustCompile(`line (\d+)`)
+
+func yamlErrorLine(err error) int {
+	var line int
+	if match := yamlErrorLineRegex.FindStringSubmatch(err.Error()); match != nil {
+		fmt.Sscan(match[1], &line)
+	}
+	return line
+}
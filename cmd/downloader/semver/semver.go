@@ -0,0 +1,233 @@
+// Package semver parses release tags as semantic versions and checks
+// them against version ranges.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Tags may have a leading "v" and leave
+// out the minor and patch numbers.
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+	// Number of the major, minor and patch components that were given
+	parts    int
+	original string
+}
+
+// Parse reads a version like v1.79.1, 0.19 or 2.0.0-rc.1. Build
+// metadata after a "+" is ignored.
+func Parse(version string) (*Version, error) {
+	v := &Version{original: version}
+	rest := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	rest, _, _ = strings.Cut(rest, "+")
+	rest, v.Prerelease, _ = strings.Cut(rest, "-")
+	numbers := strings.Split(rest, ".")
+	if len(numbers) > 3 {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	for i, number := range numbers {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		switch i {
+		case 0:
+			v.Major = n
+		case 1:
+			v.Minor = n
+		case 2:
+			v.Patch = n
+		}
+	}
+	v.parts = len(numbers)
+	return v, nil
+}
+
+func (v *Version) String() string {
+	if v.original != "" {
+		return v.original
+	}
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 when v is older than, the same as, or
+// newer than other. Pre-releases are older than their release.
+func (v *Version) Compare(other *Version) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil && an < bn:
+			return -1
+		case aErr == nil && bErr == nil:
+			return 1
+		case aErr == nil:
+			// Numeric identifiers sort before alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+type comparison struct {
+	operator string
+	version  *Version
+}
+
+// Constraint is a version range such as ">=1.79 <2", "~0.19" or
+// "^1.2 || 2.0.0". Space separated comparisons must all match, "||"
+// separates alternatives. A bare version matches only itself.
+type Constraint struct {
+	alternatives [][]comparison
+	original     string
+}
+
+// IsRange reports whether a release names a version range rather than
+// a single tag or branch, i.e. uses an operator or combines versions.
+func IsRange(release string) bool {
+	release = strings.TrimSpace(release)
+	return strings.ContainsAny(release, " |") || strings.IndexAny(release, "<>=~^") == 0
+}
+
+// ParseConstraint reads a version range.
+func ParseConstraint(constraint string) (*Constraint, error) {
+	c := &Constraint{original: constraint}
+	for _, alternative := range strings.Split(constraint, "||") {
+		var comparisons []comparison
+		fields := strings.Fields(alternative)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			operator := field[:len(field)-len(strings.TrimLeft(field, "<>=~^"))]
+			if operator == field && i+1 < len(fields) {
+				// Operator separated from its version, e.g. ">= 1.2"
+				i++
+				field += fields[i]
+			}
+			switch operator {
+			case "", "=", ">", ">=", "<", "<=", "~", "^":
+			default:
+				return nil, fmt.Errorf("invalid operator %q in version range %q", operator, constraint)
+			}
+			version, err := Parse(field[len(operator):])
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %w", constraint, err)
+			}
+			comparisons = append(comparisons, expand(operator, version)...)
+		}
+		if len(comparisons) == 0 {
+			return nil, fmt.Errorf("empty version range %q", constraint)
+		}
+		c.alternatives = append(c.alternatives, comparisons)
+	}
+	return c, nil
+}
+
+// expand turns the shorthand operators and partial versions into plain
+// comparisons.
+func expand(operator string, v *Version) []comparison {
+	lower := &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Prerelease: v.Prerelease}
+	nextMajor := &Version{Major: v.Major + 1, Prerelease: "0"}
+	nextMinor := &Version{Major: v.Major, Minor: v.Minor + 1, Prerelease: "0"}
+	// First version after all the ones a partial version like 1.2 stands for
+	next := nextMinor
+	if v.parts == 1 {
+		next = nextMajor
+	}
+	switch {
+	case (operator == "" || operator == "=") && v.parts < 3:
+		return []comparison{{">=", lower}, {"<", next}}
+	case operator == "" || operator == "=":
+		return []comparison{{"=", lower}}
+	case operator == "~":
+		return []comparison{{">=", lower}, {"<", next}}
+	case operator == "^" && (v.Major > 0 || v.parts == 1):
+		return []comparison{{">=", lower}, {"<", nextMajor}}
+	case operator == "^" && (v.Minor > 0 || v.parts == 2):
+		return []comparison{{">=", lower}, {"<", nextMinor}}
+	case operator == "^":
+		return []comparison{{">=", lower}, {"<", &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Prerelease: "0"}}}
+	case operator == "<=" && v.parts < 3:
+		return []comparison{{"<", next}}
+	case operator == "<" && v.Prerelease == "":
+		// <2 excludes the pre-releases of 2.0.0
+		return []comparison{{"<", &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Prerelease: "0"}}}
+	case operator == ">" && v.parts < 3:
+		return []comparison{{">=", &Version{Major: next.Major, Minor: next.Minor}}}
+	}
+	return []comparison{{operator, lower}}
+}
+
+func (c *Constraint) String() string {
+	return c.original
+}
+
+// Check reports whether a version is in the range.
+func (c *Constraint) Check(v *Version) bool {
+	for _, comparisons := range c.alternatives {
+		matches := true
+		for _, comparison := range comparisons {
+			result := v.Compare(comparison.version)
+			switch comparison.operator {
+			case "=":
+				matches = matches && result == 0
+			case ">":
+				matches = matches && result > 0
+			case ">=":
+				matches = matches && result >= 0
+			case "<":
+				matches = matches && result < 0
+			case "<=":
+				matches = matches && result <= 0
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	ordered := []string{"0.9.9", "v1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "v1.0.0", "1.2", "1.79.1", "2"}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, err := Parse(ordered[i])
+		require.NoError(t, err)
+		b, err := Parse(ordered[i+1])
+		require.NoError(t, err)
+		require.Equal(t, -1, a.Compare(b), "%s < %s", a, b)
+		require.Equal(t, 1, b.Compare(a), "%s > %s", b, a)
+		require.Equal(t, 0, a.Compare(a))
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, version := range []string{"", "main", "1.2.3.4", "v1.x", "1.-2"} {
+		_, err := Parse(version)
+		require.Error(t, err, version)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	for constraint, versions := range map[string]map[string]bool{
+		"~0.19":           {"0.19.0": true, "v0.19.7": true, "0.20.0": false, "0.18.9": false, "0.20.0-rc.1": false},
+		">=1.79 <2":       {"1.79.0": true, "1.80.0": true, "2.0.0": false, "1.78.9": false, "2.0.0-rc.1": false},
+		"^1.2":            {"1.2.0": true, "1.9.9": true, "2.0.0": false, "1.1.0": false},
+		"^0.2.3":          {"0.2.3": true, "0.2.9": true, "0.3.0": false},
+		"^0.0.3":          {"0.0.3": true, "0.0.4": false},
+		"1.2":             {"1.2.0": true, "1.2.9": true, "1.3.0": false},
+		"v3.2.1":          {"3.2.1": true, "3.2.2": false},
+		">= v3.2":         {"3.2.0": true, "4.0.0": true, "3.1.9": false},
+		"<=1.2":           {"1.2.9": true, "1.3.0": false},
+		">1.2":            {"1.2.9": false, "1.3.0": true},
+		"<1.0 || >=2.0.1": {"0.9.0": true, "1.5.0": false, "2.0.1": true},
+	} {
+		c, err := ParseConstraint(constraint)
+		require.NoError(t, err, constraint)
+		for version, expected := range versions {
+			v, err := Parse(version)
+			require.NoError(t, err)
+			require.Equal(t, expected, c.Check(v), "%s in %s", version, constraint)
+		}
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, constraint := range []string{"", "=>1.2", ">=main", "1.2 ||"} {
+		_, err := ParseConstraint(constraint)
+		require.Error(t, err, constraint)
+	}
+}
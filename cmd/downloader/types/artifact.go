@@ -0,0 +1,112 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+)
+
+// ArtifactFor returns the artifact to install on a platform, with all
+// variables expanded.
+func (s *Service) ArtifactFor(platform, architecture string) (*Artifact, error) {
+	artifact, err := s.TemplateFor(platform, architecture)
+	if err != nil {
+		return nil, err
+	}
+	if artifact.File, err = s.Interpolate(artifact.File, platform, architecture); err != nil {
+		return nil, err
+	}
+	for _, extract := range artifact.Extract {
+		if extract.Path, err = s.Interpolate(extract.Path, platform, architecture); err != nil {
+			return nil, err
+		}
+		if extract.Output, err = s.Interpolate(extract.Output, platform, architecture); err != nil {
+			return nil, err
+		}
+	}
+	return artifact, nil
+}
+
+// TemplateFor returns a copy of the artifact to install on a platform
+// in which only the platform variables are expanded. Services from
+// version 3 manifests derive it from binary, srcFilename(s),
+// archivePath and outputPath.
+func (s *Service) TemplateFor(platform, architecture string) (*Artifact, error) {
+	platArch := fmt.Sprintf("%s-%s", platform, architecture)
+	var artifact *Artifact
+	if s.Artifacts != nil {
+		found, ok := s.Artifacts[platArch]
+		if !ok {
+			return nil, fmt.Errorf("%s build not found in artifacts for %s", s.Name, platArch)
+		}
+		artifact = copyArtifact(found)
+	} else if s.Artifact != nil {
+		artifact = copyArtifact(s.Artifact)
+	} else {
+		derived, err := s.deriveArtifact(platform, architecture)
+		if err != nil {
+			return nil, err
+		}
+		artifact = derived
+	}
+
+	expand := func(value string) string {
+		// Unknown variables are kept, so this can't fail
+		expanded, _ := Expand(value, platformVariables(platform, architecture), true)
+		return expanded
+	}
+	artifact.File = expand(artifact.File)
+	for _, extract := range artifact.Extract {
+		extract.Path = expand(extract.Path)
+		extract.Output = expand(extract.Output)
+	}
+	return artifact, nil
+}
+
+func (s *Service) deriveArtifact(platform, architecture string) (*Artifact, error) {
+	platArch := fmt.Sprintf("%s-%s", platform, architecture)
+	extension := constants.TarFileExtension
+	if platform == "windows" {
+		extension = constants.ZipFileExtension
+	}
+	packageName := fmt.Sprintf("livepeer-%s", s.Name)
+	if len(s.Binary) > 0 {
+		packageName = s.Binary
+	}
+	artifact := &Artifact{File: fmt.Sprintf("%s-%s-%s.%s", packageName, platform, architecture, extension)}
+	// srcFilenames are exceptions to srcFilename, if there is one
+	if name, ok := s.SrcFilenames[platArch]; ok {
+		artifact.File = name
+	} else if s.SrcFilename != "" {
+		artifact.File, _ = Expand(s.SrcFilename, platformVariables(platform, architecture), true)
+	} else if s.SrcFilenames != nil {
+		return nil, fmt.Errorf("%s build not found in srcFilenames for %s", s.Name, platArch)
+	}
+
+	extract := &ExtractFile{Path: s.ArchivePath, Output: s.OutputPath}
+	// Binaries in zip archives are windows executables, which the
+	// manifest names without their .exe suffix
+	if strings.HasSuffix(artifact.File, "."+constants.ZipFileExtension) {
+		if extract.Path != "" && !strings.HasSuffix(extract.Path, ".exe") {
+			extract.Path += ".exe"
+		}
+		if extract.Output != "" && !strings.HasSuffix(extract.Output, ".exe") {
+			extract.Output += ".exe"
+		}
+	}
+	if extract.Path != "" || extract.Output != "" {
+		artifact.Extract = []*ExtractFile{extract}
+	}
+	return artifact, nil
+}
+
+func copyArtifact(artifact *Artifact) *Artifact {
+	copied := *artifact
+	copied.Extract = nil
+	for _, extract := range artifact.Extract {
+		rule := *extract
+		copied.Extract = append(copied.Extract, &rule)
+	}
+	return &copied
+}
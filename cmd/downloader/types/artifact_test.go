@@ -0,0 +1,71 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactForInterpolatesVariables(t *testing.T) {
+	t.Setenv("VM_FLAVOUR", "prod")
+	service := &Service{
+		Name:        "victoria-metrics",
+		Release:     "v1.79.1",
+		Strategy:    &DownloadStrategy{Download: "github", Project: "VictoriaMetrics/VictoriaMetrics", Commit: "1d0030ed5ef0c75e2652371aab29a5cc453e5518"},
+		SrcFilename: "${name}-${platform}-${arch}-${release}.${ext}",
+		ArchivePath: "${binary}",
+		OutputPath:  "lp-${name}-${commit}",
+		Vars:        map[string]string{"binary": "${name}-${VM_FLAVOUR}"},
+	}
+	artifact, err := service.ArtifactFor("linux", "arm64")
+	require.NoError(t, err)
+	require.Equal(t, &Artifact{
+		File:    "victoria-metrics-linux-arm64-v1.79.1.tar.gz",
+		Extract: []*ExtractFile{{Path: "victoria-metrics-prod", Output: "lp-victoria-metrics-1d0030ed5ef0c75e2652371aab29a5cc453e5518"}},
+	}, artifact)
+
+	artifact, err = service.ArtifactFor("windows", "amd64")
+	require.NoError(t, err)
+	require.Equal(t, "victoria-metrics-windows-amd64-v1.79.1.zip", artifact.File)
+	require.Equal(t, "victoria-metrics-prod.exe", artifact.Extract[0].Path)
+
+	// Templates only expand what depends on the platform
+	template, err := service.TemplateFor("darwin", "amd64")
+	require.NoError(t, err)
+	require.Equal(t, "${name}-darwin-amd64-${release}.tar.gz", template.File)
+}
+
+func TestArtifactForSrcFilenameExceptions(t *testing.T) {
+	service := &Service{
+		Name:         "vmagent",
+		Release:      "v1.80.0",
+		SrcFilename:  "vmutils-${platform}-${arch}-${release}.${ext}",
+		SrcFilenames: map[string]string{"darwin-arm64": "vmutils-darwin-arm64-v1.80.0-signed.tar.gz"},
+	}
+	artifact, err := service.ArtifactFor("darwin", "arm64")
+	require.NoError(t, err)
+	require.Equal(t, "vmutils-darwin-arm64-v1.80.0-signed.tar.gz", artifact.File)
+	artifact, err = service.ArtifactFor("linux", "amd64")
+	require.NoError(t, err)
+	require.Equal(t, "vmutils-linux-amd64-v1.80.0.tar.gz", artifact.File)
+}
+
+func TestArtifactForVersion4Template(t *testing.T) {
+	template := &Artifact{File: "mist-${platform}-${arch}.${ext}", Extract: []*ExtractFile{{Path: "MistServer"}}}
+	service := &Service{Name: "mistserver", Release: "catalyst", Artifact: template}
+	artifact, err := service.ArtifactFor("darwin", "arm64")
+	require.NoError(t, err)
+	require.Equal(t, "mist-darwin-arm64.tar.gz", artifact.File)
+	// The manifest isn't modified by expanding it
+	require.Equal(t, "mist-${platform}-${arch}.${ext}", template.File)
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	service := &Service{Name: "api", Vars: map[string]string{"a": "${b}", "b": "${a}"}}
+	_, err := service.Interpolate("${CATALYST_SURELY_UNDEFINED}", "linux", "amd64")
+	require.EqualError(t, err, "api: undefined variable ${CATALYST_SURELY_UNDEFINED}")
+	_, err = service.Interpolate("${a}", "linux", "amd64")
+	require.EqualError(t, err, "api: variable ${a} refers to itself")
+	_, err = service.Interpolate("${release", "linux", "amd64")
+	require.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+)
+
+// Expand replaces `${name}` references in a manifest value. lookup
+// resolves a name, references it can't resolve are an error, unless
+// keepUnknown is set, in which case they're left in place.
+func Expand(value string, lookup func(name string) (string, bool), keepUnknown bool) (string, error) {
+	var expanded strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in %q", value)
+		}
+		name := value[start+2 : start+end]
+		expanded.WriteString(value[:start])
+		if resolved, ok := lookup(name); ok {
+			expanded.WriteString(resolved)
+		} else if keepUnknown {
+			expanded.WriteString(value[start : start+end+1])
+		} else {
+			return "", fmt.Errorf("undefined variable ${%s}", name)
+		}
+		value = value[start+end+1:]
+	}
+	expanded.WriteString(value)
+	return expanded.String(), nil
+}
+
+// platformVariables resolves the variables that only depend on the
+// platform an artifact is for.
+func platformVariables(platform, architecture string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		switch name {
+		case "platform":
+			return platform, true
+		case "arch":
+			return architecture, true
+		case "ext":
+			if platform == "windows" {
+				return constants.ZipFileExtension, true
+			}
+			return constants.TarFileExtension, true
+		}
+		return "", false
+	}
+}
+
+// Interpolate expands every variable in a manifest value of the
+// service: `${release}` (the pinned release of version ranges),
+// `${commit}`, `${name}`, `${platform}`, `${arch}` and `${ext}`, then
+// the manifest's `vars`, which may refer to other variables, then
+// environment variables.
+func (s *Service) Interpolate(value, platform, architecture string) (string, error) {
+	expanding := map[string]bool{}
+	var lookup func(string) (string, bool)
+	var err error
+	lookup = func(name string) (string, bool) {
+		if value, ok := platformVariables(platform, architecture)(name); ok {
+			return value, true
+		}
+		switch name {
+		case "release":
+			return s.ReleaseTag(), true
+		case "commit":
+			if s.Strategy == nil {
+				return "", true
+			}
+			return s.Strategy.Commit, true
+		case "name":
+			return s.Name, true
+		}
+		if value, ok := s.Vars[name]; ok {
+			if expanding[name] {
+				err = fmt.Errorf("variable ${%s} refers to itself", name)
+				return "", true
+			}
+			expanding[name] = true
+			defer delete(expanding, name)
+			expanded, expandErr := Expand(value, lookup, false)
+			if expandErr != nil && err == nil {
+				err = expandErr
+			}
+			return expanded, true
+		}
+		return os.LookupEnv(name)
+	}
+	expanded, expandErr := Expand(value, lookup, false)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", s.Name, err)
+	}
+	if expandErr != nil {
+		return "", fmt.Errorf("%s: %w", s.Name, expandErr)
+	}
+	return expanded, nil
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type gitRefObject struct {
 	SHA  string `json:"sha"`
 	Type string `json:"type"`
@@ -19,6 +21,41 @@ type TagInformation struct {
 	TagName    string `json:"tag_name"`
 	Draft      bool   `json:"draft"`
 	Commit     string `json:"commit,omitempty"`
+	Body       string `json:"body,omitempty"`
+	HTMLURL    string `json:"html_url,omitempty"`
+	// RFC 3339 time the release was published
+	PublishedAt string          `json:"published_at,omitempty"`
+	Assets      []*ReleaseAsset `json:"assets,omitempty"`
+}
+
+// ReleaseAsset is a file attached to a github release.
+type ReleaseAsset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	// sha256:<hex>, for assets uploaded since github publishes digests
+	Digest string `json:"digest,omitempty"`
+}
+
+type commitSignature struct {
+	Date string `json:"date"`
+}
+
+type commitMessage struct {
+	Message   string          `json:"message"`
+	Committer commitSignature `json:"committer"`
+}
+
+type CommitInformation struct {
+	SHA    string        `json:"sha"`
+	Commit commitMessage `json:"commit"`
+}
+
+// CompareInformation is the github API's comparison of two commits.
+type CompareInformation struct {
+	TotalCommits int                  `json:"total_commits"`
+	BaseCommit   *CommitInformation   `json:"base_commit"`
+	Commits      []*CommitInformation `json:"commits"`
 }
 
 type BuildManifestInformation struct {
@@ -34,16 +71,40 @@ type BuildFlags struct {
 }
 
 type CliFlags struct {
-	SkipDownloaded bool
-	Cleanup        bool
-	UpdateManifest bool
-	Download       bool
-	DownloadPath   string
-	Platform       string
-	Architecture   string
-	ManifestFile   string
-	Verbosity      string
-	ExecCommand    []string
+	SkipDownloaded   bool
+	Cleanup          bool
+	UpdateManifest   bool
+	Download         bool
+	DownloadPath     string
+	Platform         string
+	Architecture     string
+	ManifestFile     string
+	Verbosity        string
+	ExecCommand      []string
+	Command          []string
+	VerifyIgnore     []string
+	VerifyRehash     bool
+	MaxGlibc         string
+	ManifestOverlays []string
+	UpdateSummary    string
+	Only             []string
+	Exclude          []string
+	Group            []string
+	JSON             bool
+	GitHubAPI        string
+	GitHubDownload   string
+	BucketURL        string
+	CABundle         string
+	ClientCert       string
+	ClientKey        string
+	AllowHTTP        []string
+	Credentials      []string
+	InsecureManifest bool
+	AllowRemoteHooks bool
+	S3Endpoint       string
+	LockTimeout      time.Duration
+	GCKeep           int
+	GCMaxAge         time.Duration
 
 	ManifestURL bool
 }
@@ -52,6 +113,34 @@ type DownloadStrategy struct {
 	Download string `yaml:"download,omitempty"`
 	Project  string `yaml:"project"`
 	Commit   string `yaml:"commit,omitempty"`
+	// GitHub repository of bucket projects, livepeer/<project> by default
+	Repository string `yaml:"repository,omitempty"`
+	// Release asset names of github projects that -update-manifest
+	// regenerates srcFilenames from
+	AssetPattern string `yaml:"assetPattern,omitempty"`
+	// Root of the bucket, or of the github release downloads, to fetch
+	// artifacts from instead of the default, e.g. a mirror
+	BaseURL string `yaml:"baseURL,omitempty"`
+}
+
+// Hook is a command run from the download path around the install of
+// a service. The output of `check` hooks can be matched against a
+// regular expression and/or the pinned commit of the service.
+type Hook struct {
+	Command      []string `yaml:"command"`
+	Expect       string   `yaml:"expect,omitempty"`
+	ExpectCommit bool     `yaml:"expectCommit,omitempty"`
+	Timeout      string   `yaml:"timeout,omitempty"`
+}
+
+// Requirement constrains the release and/or pinned commit of another
+// service that a service works with.
+type Requirement struct {
+	Service string `yaml:"service"`
+	// Version range the release of the service must be in
+	Release string `yaml:"release,omitempty"`
+	// Commits (or prefixes of them) the service may be pinned to
+	Commits []string `yaml:"commits,omitempty"`
 }
 
 type Service struct {
@@ -64,15 +153,78 @@ type Service struct {
 	SkipGPG      bool              `yaml:"skipGpg,omitempty"`
 	SkipChecksum bool              `yaml:"skipChecksum,omitempty"`
 	SrcFilenames map[string]string `yaml:"srcFilenames,omitempty"`
+	SrcFilename  string            `yaml:"srcFilename,omitempty"`
 	OutputPath   string            `yaml:"outputPath,omitempty"`
+	PreInstall   *Hook             `yaml:"preInstall,omitempty"`
+	PostInstall  *Hook             `yaml:"postInstall,omitempty"`
+	Check        *Hook             `yaml:"check,omitempty"`
+	Tags         []string          `yaml:"tags,omitempty"`
+	Requires     []*Requirement    `yaml:"requires,omitempty"`
 
 	SkipManifestUpdate bool `yaml:"skipManifestUpdate,omitempty"`
+	// Release a version range in Release was resolved to
+	PinnedRelease   string `yaml:"pinnedRelease,omitempty"`
+	AllowPrerelease bool   `yaml:"allowPrerelease,omitempty"`
+
+	// Explicit per-platform artifacts of version 4 manifests. When set,
+	// they take precedence over binary, srcFilenames, archivePath and
+	// outputPath.
+	Artifacts map[string]*Artifact `yaml:"-"`
+	// Artifact of version 4 manifests used for every platform
+	Artifact *Artifact `yaml:"-"`
+	// Variables of the manifest, see BoxManifest.PropagateVars
+	Vars map[string]string `yaml:"-"`
+}
+
+// ReleaseTag returns the release to install: the one a version range
+// was pinned to, or the release itself.
+func (s *Service) ReleaseTag() string {
+	if s.PinnedRelease != "" {
+		return s.PinnedRelease
+	}
+	return s.Release
+}
+
+// Clone returns a copy of the service, whose strategy and artifacts can
+// be changed without changing the service.
+func (s *Service) Clone() *Service {
+	clone := *s
+	if s.Strategy != nil {
+		strategy := *s.Strategy
+		clone.Strategy = &strategy
+	}
+	if s.SrcFilenames != nil {
+		clone.SrcFilenames = make(map[string]string, len(s.SrcFilenames))
+		for platArch, file := range s.SrcFilenames {
+			clone.SrcFilenames[platArch] = file
+		}
+	}
+	if s.Artifacts != nil {
+		clone.Artifacts = make(map[string]*Artifact, len(s.Artifacts))
+		for platArch, artifact := range s.Artifacts {
+			clone.Artifacts[platArch] = copyArtifact(artifact)
+		}
+	}
+	if s.Artifact != nil {
+		clone.Artifact = copyArtifact(s.Artifact)
+	}
+	return &clone
 }
 
 type BoxManifest struct {
-	Version string     `yaml:"version"`
-	Release string     `yaml:"release,omitempty"`
-	Box     []*Service `yaml:"box,omitempty"`
+	Version string              `yaml:"version"`
+	Release string              `yaml:"release,omitempty"`
+	Vars    map[string]string   `yaml:"vars,omitempty"`
+	Groups  map[string][]string `yaml:"groups,omitempty"`
+	Box     []*Service          `yaml:"box,omitempty"`
+}
+
+// PropagateVars makes the manifest's variables available to the
+// interpolation of its services. Call it whenever Vars or Box change.
+func (m *BoxManifest) PropagateVars() {
+	for _, service := range m.Box {
+		service.Vars = m.Vars
+	}
 }
 
 type ArtifactInfo struct {
@@ -87,4 +239,71 @@ type ArtifactInfo struct {
 	ChecksumFileName  string
 	SignatureURL      string
 	SignatureFileName string
+	Digest            string
+	Size              int64 // of the archive in bytes, 0 if unknown
+	Extract           []*ExtractFile
+}
+
+// Artifact is the archive (or bare binary) of a service for a single
+// platform, and the files to take out of it.
+type Artifact struct {
+	File    string         `yaml:"file"`
+	Digest  string         `yaml:"digest,omitempty"`
+	Extract []*ExtractFile `yaml:"extract,omitempty"`
+}
+
+// ExtractFile selects the archive entries whose name ends in Path and
+// names the file they're extracted to. An empty Path selects every
+// entry, an empty Output keeps the entry's base name.
+type ExtractFile struct {
+	Path   string `yaml:"path,omitempty"`
+	Output string `yaml:"output,omitempty"`
+}
+
+// BoxManifestV4 is the version 4 manifest schema. It's converted from
+// and to BoxManifest, which the rest of the downloader works with.
+type BoxManifestV4 struct {
+	Version  string              `yaml:"version"`
+	Release  string              `yaml:"release,omitempty"`
+	Vars     map[string]string   `yaml:"vars,omitempty"`
+	Groups   map[string][]string `yaml:"groups,omitempty"`
+	Services []*ServiceV4        `yaml:"services"`
+}
+
+type ServiceV4 struct {
+	Name               string               `yaml:"name"`
+	Release            string               `yaml:"release,omitempty"`
+	PinnedRelease      string               `yaml:"pinnedRelease,omitempty"`
+	AllowPrerelease    bool                 `yaml:"allowPrerelease,omitempty"`
+	Commit             string               `yaml:"commit,omitempty"`
+	Strategy           *StrategyV4          `yaml:"strategy"`
+	Artifacts          map[string]*Artifact `yaml:"artifacts,omitempty"`
+	Artifact           *Artifact            `yaml:"artifact,omitempty"`
+	Skip               bool                 `yaml:"skip,omitempty"`
+	SkipGPG            bool                 `yaml:"skipGpg,omitempty"`
+	SkipChecksum       bool                 `yaml:"skipChecksum,omitempty"`
+	SkipManifestUpdate bool                 `yaml:"skipManifestUpdate,omitempty"`
+	PreInstall         *Hook                `yaml:"preInstall,omitempty"`
+	PostInstall        *Hook                `yaml:"postInstall,omitempty"`
+	Check              *Hook                `yaml:"check,omitempty"`
+	Tags               []string             `yaml:"tags,omitempty"`
+	Requires           []*Requirement       `yaml:"requires,omitempty"`
+}
+
+// StrategyV4 holds the options block of exactly one download strategy.
+type StrategyV4 struct {
+	Bucket *BucketStrategy `yaml:"bucket,omitempty"`
+	GitHub *GitHubStrategy `yaml:"github,omitempty"`
+}
+
+type BucketStrategy struct {
+	Project    string `yaml:"project"`
+	Repository string `yaml:"repository,omitempty"`
+	BaseURL    string `yaml:"baseURL,omitempty"`
+}
+
+type GitHubStrategy struct {
+	Project      string `yaml:"project"`
+	AssetPattern string `yaml:"assetPattern,omitempty"`
+	BaseURL      string `yaml:"baseURL,omitempty"`
 }
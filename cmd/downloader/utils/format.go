@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
+)
+
+// Formats manifests can be written in
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// A table header or key/value pair, which YAML writes as `key: value`
+var tomlLineRegex = regexp.MustCompile(`^(\[\[?\s*[A-Za-z0-9_."-]+\s*\]\]?|[A-Za-z0-9_."-]+\s*=)`)
+
+// ManifestFormat tells the format of a manifest from the extension of
+// its path or URL, or else from its first line.
+func ManifestFormat(name string, data []byte) string {
+	// Not mistaking windows drive letters for schemes
+	if parsed, err := url.Parse(name); err == nil && len(parsed.Scheme) > 1 {
+		name = parsed.Path
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "---" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "{"):
+			return FormatJSON
+		case tomlLineRegex.MatchString(line):
+			return FormatTOML
+		}
+		break
+	}
+	return FormatYAML
+}
+
+// NormalizeManifest converts a manifest to a document that the YAML
+// decoder reads into the same types. JSON already is one, TOML is
+// converted to JSON.
+func NormalizeManifest(name string, data []byte) ([]byte, error) {
+	if ManifestFormat(name, data) != FormatTOML {
+		return data, nil
+	}
+	var document map[string]interface{}
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid TOML manifest: %w", err)
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// IssueString formats an issue of a manifest written in format. TOML
+// manifests are validated as the JSON they're converted to, whose lines
+// mean nothing to the reader, so their issues name the key path instead.
+func IssueString(manifestPath, format string, issue schema.Issue) string {
+	switch {
+	case format != FormatTOML:
+		return fmt.Sprintf("%s:%s", manifestPath, issue)
+	case issue.Path == "":
+		return fmt.Sprintf("%s: %s: %s", manifestPath, issue.Severity, issue.Message)
+	}
+	return fmt.Sprintf("%s:%s: %s: %s", manifestPath, issue.Path, issue.Severity, issue.Message)
+}
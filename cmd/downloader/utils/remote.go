@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
+	"github.com/livepeer/catalyst/cmd/downloader/verification"
+	glog "github.com/magicsong/color-glog"
+)
+
+// InsecureManifest lets remote manifests through that aren't signed,
+// or that turn off the verification of a service.
+var InsecureManifest bool
+
+// AllowRemoteHooks lets remote manifests through that run hooks.
+// InsecureManifest doesn't, as hooks run arbitrary commands.
+var AllowRemoteHooks bool
+
+// ManifestCacheDir keeps the last remote manifest that was fetched, to
+// revalidate it and to fall back to when it can't be fetched. No
+// caching if empty.
+var ManifestCacheDir string
+
+// manifestCache is a remote manifest as it was last fetched.
+type manifestCache struct {
+	// Redacted URL of the manifest
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Manifest     []byte `json:"manifest"`
+	// Detached signature, none if the manifest was used because of
+	// InsecureManifest
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// readRemoteManifest fetches a manifest and checks its signature. If it
+// can't be fetched, the last copy that was is used instead.
+func readRemoteManifest(manifestURL string) ([]byte, error) {
+	cached := readManifestCache(manifestURL)
+	fetched, err := fetchManifest(manifestURL, cached)
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		glog.Errorf("!!! %s", err)
+		glog.Errorf("!!! FALLING BACK TO THE COPY OF %s CACHED IN %s, IT MAY BE OUT OF DATE", network.Redact(manifestURL), ManifestCacheDir)
+		fetched = cached
+	}
+	if err := checkManifestSignature(manifestURL, fetched); err != nil {
+		return nil, err
+	}
+	// Only keep manifests worth falling back to
+	if fetched != cached {
+		if normalized, err := NormalizeManifest(manifestURL, fetched.Manifest); err == nil && !schema.HasErrors(schema.Validate(normalized)) {
+			writeManifestCache(fetched)
+		}
+	}
+	return fetched.Manifest, nil
+}
+
+// fetchManifest fetches a manifest and its signature, unless the cached
+// copy is still current.
+func fetchManifest(manifestURL string, cached *manifestCache) (*manifestCache, error) {
+	var current *network.Object
+	if cached != nil {
+		current = &network.Object{Data: cached.Manifest, ETag: cached.ETag, LastModified: cached.LastModified}
+	}
+	object, err := network.Fetch(manifestURL, current)
+	if err != nil {
+		return nil, err
+	}
+	if object == current {
+		glog.V(5).Infof("manifest %s hasn't changed", network.Redact(manifestURL))
+		return cached, nil
+	}
+	fetched := &manifestCache{
+		URL:          network.Redact(manifestURL),
+		ETag:         object.ETag,
+		LastModified: object.LastModified,
+		Manifest:     object.Data,
+	}
+	fetched.Signature, err = fetchManifestSignature(manifestURL)
+	if err != nil && !InsecureManifest {
+		return nil, fmt.Errorf("%w, pass -insecure-manifest to use it anyway", err)
+	} else if err != nil {
+		glog.Warningf("%s, using it anyway because of -insecure-manifest", err)
+	}
+	return fetched, nil
+}
+
+// fetchManifestSignature fetches the detached signature published next
+// to a remote manifest, at the same URL with `.sig` appended to the
+// path.
+func fetchManifestSignature(manifestURL string) ([]byte, error) {
+	parsed, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	parsed.Path += "." + constants.SignatureFileExtension
+	signatureURL := parsed.String()
+	object, err := network.Fetch(signatureURL, nil)
+	if errors.Is(err, network.ErrNotFound) {
+		return nil, fmt.Errorf("manifest %s isn't signed, found no %s", network.Redact(manifestURL), network.Redact(signatureURL))
+	} else if err != nil {
+		return nil, fmt.Errorf("can't fetch the signature of manifest %s: %w", network.Redact(manifestURL), err)
+	}
+	return object.Data, nil
+}
+
+// checkManifestSignature verifies a fetched or cached manifest against
+// its signature. Cached copies are checked again, as the cache is only
+// as safe as the download path.
+func checkManifestSignature(manifestURL string, fetched *manifestCache) error {
+	if fetched.Signature == nil {
+		if InsecureManifest {
+			return nil
+		}
+		return fmt.Errorf("manifest %s isn't signed, pass -insecure-manifest to use it anyway", network.Redact(manifestURL))
+	}
+	if err := verification.VerifyDetachedSignature(fetched.Manifest, fetched.Signature); err != nil {
+		err = fmt.Errorf("invalid signature of manifest %s: %w", network.Redact(manifestURL), err)
+		if !InsecureManifest {
+			return fmt.Errorf("%w, pass -insecure-manifest to use it anyway", err)
+		}
+		glog.Warningf("%s, using it anyway because of -insecure-manifest", err)
+		return nil
+	}
+	glog.V(5).Infof("manifest %s is signed", network.Redact(manifestURL))
+	return nil
+}
+
+func manifestCachePath() string {
+	return filepath.Join(ManifestCacheDir, constants.ManifestCacheFileName)
+}
+
+func readManifestCache(manifestURL string) *manifestCache {
+	if ManifestCacheDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(manifestCachePath())
+	if err != nil {
+		return nil
+	}
+	var cached manifestCache
+	if err := json.Unmarshal(data, &cached); err != nil {
+		glog.Warningf("ignoring invalid manifest cache %s: %s", manifestCachePath(), err)
+		return nil
+	}
+	// Caches a manifest from another URL
+	if cached.URL != network.Redact(manifestURL) || cached.Manifest == nil {
+		return nil
+	}
+	return &cached
+}
+
+func writeManifestCache(fetched *manifestCache) {
+	if ManifestCacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(fetched)
+	if err == nil {
+		err = os.WriteFile(manifestCachePath(), data, 0644)
+	}
+	if err != nil {
+		glog.Warningf("not caching the manifest: %s", err)
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
+	"regexp"
 	"strings"
 
-	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
+	"github.com/livepeer/catalyst/cmd/downloader/schema"
 	"github.com/livepeer/catalyst/cmd/downloader/types"
 	glog "github.com/magicsong/color-glog"
 	"gopkg.in/yaml.v3"
@@ -16,51 +17,127 @@ import (
 
 func IsSupportedPlatformArch(platform, arch string) bool {
 	glog.Infof("checking if we support platform=%q and arch=%q", platform, arch)
-	switch platform {
-	case "linux",
-		"darwin":
-		switch arch {
-		case "arm64",
-			"amd64":
+	for _, platArch := range schema.SupportedPlatforms {
+		if platArch == platform+"-"+arch {
 			return true
 		}
-		break
-	case "windows":
-		return arch == "amd64"
 	}
 	return false
 }
 
-func ParseYamlManifest(manifestPath string, isURL bool) (*types.BoxManifest, error) {
-	var manifestConfig types.BoxManifest
-	var file []byte
+// ReadManifest returns the contents of a manifest file, URL, or stdin
+// for "-", as a document the YAML decoder reads, see NormalizeManifest.
+// Remote manifests must come with a valid detached signature, and fall
+// back to the last one fetched if they can't be, see readRemoteManifest.
+func ReadManifest(manifestPath string, isURL bool) ([]byte, error) {
+	data, _, err := ReadManifestFormat(manifestPath, isURL)
+	return data, err
+}
+
+// ReadManifestFormat is ReadManifest that also tells the format the
+// manifest was written in, see ManifestFormat.
+func ReadManifestFormat(manifestPath string, isURL bool) ([]byte, string, error) {
+	glog.Infof("reading manifest file=%q", network.Redact(manifestPath))
+	glog.V(9).Infof("manifestPath=%s isURL=%t", network.Redact(manifestPath), isURL)
+	var data []byte
 	var err error
-	glog.Infof("reading manifest file=%q", manifestPath)
-	glog.V(9).Infof("manifestPath=%s isURL=%t", manifestPath, isURL)
-	if !isURL {
-		file, err = ioutil.ReadFile(manifestPath)
-		if err != nil {
-			return nil, err
+	switch {
+	case isURL:
+		data, err = readRemoteManifest(manifestPath)
+	case manifestPath == "-":
+		data, err = io.ReadAll(os.Stdin)
+	default:
+		data, err = ioutil.ReadFile(manifestPath)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	format := ManifestFormat(manifestPath, data)
+	data, err = NormalizeManifest(manifestPath, data)
+	return data, format, err
+}
+
+// UnverifiedServices lists the services of a manifest that skip the
+// GPG or checksum verification of their artifacts.
+func UnverifiedServices(m *types.BoxManifest) []string {
+	var names []string
+	for _, service := range m.Box {
+		if service.SkipGPG || service.SkipChecksum {
+			names = append(names, service.Name)
 		}
-	} else {
-		response, err := http.Get(manifestPath)
-		if err != nil || response.StatusCode != http.StatusOK {
-			return nil, err
+	}
+	return names
+}
+
+// HookedServices lists the services of a manifest that run commands
+// from their preInstall, postInstall or check hooks.
+func HookedServices(m *types.BoxManifest) []string {
+	var names []string
+	for _, service := range m.Box {
+		if service.PreInstall != nil || service.PostInstall != nil || service.Check != nil {
+			names = append(names, service.Name)
 		}
-		glog.V(9).Infof("response=%v", response)
-		file, err = ioutil.ReadAll(response.Body)
-		if err != nil {
-			return nil, err
+	}
+	return names
+}
+
+func ParseYamlManifest(manifestPath string, isURL bool) (*types.BoxManifest, error) {
+	m, err := parseYamlManifest(manifestPath, isURL)
+	if err != nil || !isURL {
+		return m, err
+	}
+	// A remote manifest mustn't lower the guard on what it installs
+	if unverified := UnverifiedServices(m); len(unverified) > 0 {
+		err := fmt.Errorf("manifest %s turns off the verification of %s", network.Redact(manifestPath), strings.Join(unverified, ", "))
+		if !InsecureManifest {
+			return nil, fmt.Errorf("%w, pass -insecure-manifest to use it anyway", err)
+		}
+		glog.Warningf("%s, using it anyway because of -insecure-manifest", err)
+	}
+	// Nor run commands on the host, signed or not
+	if hooked := HookedServices(m); len(hooked) > 0 {
+		err := fmt.Errorf("manifest %s runs hooks of %s", network.Redact(manifestPath), strings.Join(hooked, ", "))
+		if !AllowRemoteHooks {
+			return nil, fmt.Errorf("%w, pass -allow-remote-hooks to run them", err)
 		}
+		glog.Warningf("%s, running them because of -allow-remote-hooks", err)
+	}
+	return m, nil
+}
+
+func parseYamlManifest(manifestPath string, isURL bool) (*types.BoxManifest, error) {
+	var manifestConfig types.BoxManifest
+	file, format, err := ReadManifestFormat(manifestPath, isURL)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, issue := range schema.Validate(file) {
+		if issue.Severity == schema.SeverityError {
+			errs = append(errs, IssueString(manifestPath, format, issue))
+		} else {
+			glog.V(5).Info(IssueString(manifestPath, format, issue))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid manifest, run `catalyst manifest validate` for details:\n%s", strings.Join(errs, "\n"))
 	}
 	err = yaml.Unmarshal(file, &manifestConfig)
 	if err != nil {
 		return nil, err
 	}
-	if manifestConfig.Version != "3.0" {
-		return nil, fmt.Errorf("invalid manifest version %q. Currently supported versions: 3.0", manifestConfig.Version)
+	switch manifestConfig.Version {
+	case "3.0":
+		manifestConfig.PropagateVars()
+		return &manifestConfig, nil
+	case "4.0":
+		var v4 types.BoxManifestV4
+		if err := yaml.Unmarshal(file, &v4); err != nil {
+			return nil, err
+		}
+		return schema.FromV4(&v4), nil
 	}
-	return &manifestConfig, nil
+	return nil, fmt.Errorf("invalid manifest version %q. Currently supported versions: %s", manifestConfig.Version, strings.Join(schema.Versions(), ", "))
 }
 
 func IsFileExists(path string) bool {
@@ -69,37 +146,33 @@ func IsFileExists(path string) bool {
 	return err == nil && info.Size() > 0
 }
 
-func CleanBranchName(branch string) string {
-	return strings.ReplaceAll(branch, "/", "-")
-}
+// Commit hashes abbreviated below git's default of 7 characters are too
+// ambiguous to match on
+var commitRegex = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
 
-func PlatformExt(platform string) string {
-	glog.V(7).Infof("fetching archive extension for %q systems.", platform)
-	platformExtMap := map[string]string{
-		"linux":   constants.TarFileExtension,
-		"darwin":  constants.TarFileExtension,
-		"windows": constants.ZipFileExtension,
-	}
-	return platformExtMap[platform]
+// SameCommit reports whether two commit hashes, either of which may be
+// abbreviated to at least 7 characters, name the same commit.
+func SameCommit(a, b string) bool {
+	return commitRegex.MatchString(a) && commitRegex.MatchString(b) && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a))
 }
 
-func IsCleanupFile(name string) bool {
-	return strings.HasSuffix(name, constants.ZipFileExtension) || strings.HasSuffix(name, constants.TarFileExtension) || strings.HasSuffix(name, ".sig") || strings.HasSuffix(name, "_checksums.txt")
+func CleanBranchName(branch string) string {
+	return strings.ReplaceAll(branch, "/", "-")
 }
 
 func DownloadFile(path, url string, skipDownloaded bool) error {
-	glog.V(9).Infof("Downloading %s", url)
+	glog.V(9).Infof("Downloading %s", network.Redact(url))
 	if skipDownloaded && IsFileExists(path) {
 		glog.Infof("File already downloaded. Skipping!")
 		return nil
 	}
-	resp, err := http.Get(url)
+	resp, err := network.Get(url)
 	if err != nil {
 		return err
 	}
 	glog.V(9).Infof("Response statusCode=%d", resp.StatusCode)
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("HTTP %d while downloading %s", resp.StatusCode, url)
+		return fmt.Errorf("HTTP %d while downloading %s", resp.StatusCode, network.Redact(url))
 	}
 	defer resp.Body.Close()
 	tempPath := fmt.Sprintf("%s.TEMP", path)
@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/livepeer/catalyst/cmd/downloader/constants"
+	"github.com/livepeer/catalyst/cmd/downloader/network"
+	"github.com/livepeer/catalyst/cmd/downloader/verification"
+	"github.com/stretchr/testify/require"
+)
+
+const remoteManifest = `version: "3.0"
+box:
+  - name: mistserver
+    release: catalyst
+    strategy:
+      download: bucket
+      project: mistserver
+`
+
+// serveManifests serves manifests by path, and signatures of the
+// contents in signed at their paths with .sig appended. The signing key
+// becomes the trusted one.
+func serveManifests(t *testing.T, files, signed map[string]string) string {
+	sign := trustTestKey(t)
+	responses := map[string][]byte{}
+	for path, content := range files {
+		responses[path] = []byte(content)
+	}
+	for path, content := range signed {
+		responses[path+".sig"] = sign(content)
+	}
+	return serve(t, func(w http.ResponseWriter, r *http.Request) {
+		data, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+}
+
+// trustTestKey makes a new key the trusted one, and returns a function
+// signing with it.
+func trustTestKey(t *testing.T) func(content string) []byte {
+	key, err := crypto.GenerateKey("catalyst test", "test@example.com", "x25519", 0)
+	require.NoError(t, err)
+	armored, err := key.GetArmoredPublicKey()
+	require.NoError(t, err)
+	previous := verification.PublicKey
+	verification.PublicKey = armored
+	t.Cleanup(func() { verification.PublicKey = previous })
+	keyRing, err := crypto.NewKeyRing(key)
+	require.NoError(t, err)
+	return func(content string) []byte {
+		signature, err := keyRing.SignDetached(crypto.NewPlainMessage([]byte(content)))
+		require.NoError(t, err)
+		return signature.GetBinary()
+	}
+}
+
+func serve(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	require.NoError(t, network.Configure(network.Options{AllowHTTP: []string{"127.0.0.1"}}))
+	t.Cleanup(func() { network.Configure(network.Options{}) })
+	return server.URL
+}
+
+func TestRemoteManifestSignature(t *testing.T) {
+	tampered := remoteManifest + "    skip: true\n"
+	base := serveManifests(t, map[string]string{
+		"/signed.yaml":   remoteManifest,
+		"/unsigned.yaml": remoteManifest,
+		"/tampered.yaml": tampered,
+	}, map[string]string{
+		"/signed.yaml":   remoteManifest,
+		"/tampered.yaml": remoteManifest,
+	})
+
+	m, err := ParseYamlManifest(base+"/signed.yaml", true)
+	require.NoError(t, err)
+	require.Equal(t, "mistserver", m.Box[0].Name)
+
+	_, err = ParseYamlManifest(base+"/unsigned.yaml", true)
+	require.EqualError(t, err, "manifest "+base+"/unsigned.yaml isn't signed, found no "+base+"/unsigned.yaml.sig, pass -insecure-manifest to use it anyway")
+	_, err = ParseYamlManifest(base+"/tampered.yaml", true)
+	require.ErrorContains(t, err, "invalid signature of manifest "+base+"/tampered.yaml")
+
+	defer func() { InsecureManifest = false }()
+	InsecureManifest = true
+	m, err = ParseYamlManifest(base+"/tampered.yaml", true)
+	require.NoError(t, err)
+	require.True(t, m.Box[0].Skip)
+}
+
+func TestRemoteManifestTurningOffVerification(t *testing.T) {
+	unverified := remoteManifest + "    skipGpg: true\n"
+	base := serveManifests(t, map[string]string{"/manifest.yaml": unverified}, map[string]string{"/manifest.yaml": unverified})
+
+	_, err := ParseYamlManifest(base+"/manifest.yaml", true)
+	require.EqualError(t, err, "manifest "+base+"/manifest.yaml turns off the verification of mistserver, pass -insecure-manifest to use it anyway")
+
+	defer func() { InsecureManifest = false }()
+	InsecureManifest = true
+	_, err = ParseYamlManifest(base+"/manifest.yaml", true)
+	require.NoError(t, err)
+}
+
+func TestRemoteManifestRunningHooks(t *testing.T) {
+	hooked := remoteManifest + "    postInstall:\n      command: [\"true\"]\n"
+	base := serveManifests(t, map[string]string{"/manifest.yaml": hooked}, map[string]string{"/manifest.yaml": hooked})
+
+	_, err := ParseYamlManifest(base+"/manifest.yaml", true)
+	require.EqualError(t, err, "manifest "+base+"/manifest.yaml runs hooks of mistserver, pass -allow-remote-hooks to run them")
+
+	// Not a way around it
+	defer func() { InsecureManifest = false }()
+	InsecureManifest = true
+	_, err = ParseYamlManifest(base+"/manifest.yaml", true)
+	require.EqualError(t, err, "manifest "+base+"/manifest.yaml runs hooks of mistserver, pass -allow-remote-hooks to run them")
+
+	defer func() { AllowRemoteHooks = false }()
+	InsecureManifest, AllowRemoteHooks = false, true
+	m, err := ParseYamlManifest(base+"/manifest.yaml", true)
+	require.NoError(t, err)
+	require.NotNil(t, m.Box[0].PostInstall)
+}
+
+func TestRemoteManifestCache(t *testing.T) {
+	sign := trustTestKey(t)
+	signature := sign(remoteManifest)
+	down, fetches := false, 0
+	base := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/manifest.yaml.sig":
+			w.Write(signature)
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			fetches++
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(remoteManifest))
+		}
+	})
+	manifestURL := base + "/manifest.yaml"
+
+	_, err := ReadManifest(manifestURL, true)
+	require.NoError(t, err, "caching is optional")
+	defer func() { ManifestCacheDir = "" }()
+	ManifestCacheDir = t.TempDir()
+
+	down = true
+	_, err = ReadManifest(manifestURL, true)
+	require.EqualError(t, err, "HTTP 503 while fetching "+manifestURL)
+
+	down = false
+	data, err := ReadManifest(manifestURL, true)
+	require.NoError(t, err)
+	require.Equal(t, remoteManifest, string(data))
+	data, err = ReadManifest(manifestURL, true)
+	require.NoError(t, err)
+	require.Equal(t, remoteManifest, string(data))
+	require.Equal(t, 2, fetches, "the cached copy is revalidated")
+
+	// Last known good
+	down = true
+	data, err = ReadManifest(manifestURL, true)
+	require.NoError(t, err)
+	require.Equal(t, remoteManifest, string(data))
+	_, err = ReadManifest(base+"/other.yaml", true)
+	require.EqualError(t, err, "HTTP 503 while fetching "+base+"/other.yaml")
+
+	// The cache is verified too
+	require.NoError(t, os.WriteFile(filepath.Join(ManifestCacheDir, constants.ManifestCacheFileName), []byte(strings.Replace(readCacheFile(t), `"signature":`, `"ignored":`, 1)), 0644))
+	_, err = ReadManifest(manifestURL, true)
+	require.EqualError(t, err, "manifest "+manifestURL+" isn't signed, pass -insecure-manifest to use it anyway")
+}
+
+func readCacheFile(t *testing.T) string {
+	data, err := os.ReadFile(filepath.Join(ManifestCacheDir, constants.ManifestCacheFileName))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestManifestFormat(t *testing.T) {
+	require.Equal(t, FormatJSON, ManifestFormat("manifest.json", nil))
+	require.Equal(t, FormatTOML, ManifestFormat("https://example.com/manifest.TOML?v=2", nil))
+	require.Equal(t, FormatYAML, ManifestFormat("s3://bucket/manifest.yml", []byte(`{"version": "3.0"}`)))
+	require.Equal(t, FormatJSON, ManifestFormat("-", []byte("\n  {\"version\": \"3.0\"}")))
+	require.Equal(t, FormatTOML, ManifestFormat("-", []byte("# catalyst\nversion = \"3.0\"\n")))
+	require.Equal(t, FormatTOML, ManifestFormat("-", []byte("[[box]]\nname = \"mistserver\"\n")))
+	require.Equal(t, FormatYAML, ManifestFormat("-", []byte("---\nversion: \"3.0\"\n")))
+	require.Equal(t, FormatYAML, ManifestFormat("-", nil))
+}
+
+func TestManifestFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"manifest.yaml": remoteManifest,
+		"manifest.json": `{"version": "3.0", "box": [{"name": "mistserver", "release": "catalyst", "strategy": {"download": "bucket", "project": "mistserver"}}]}`,
+		"manifest.toml": `version = "3.0"
+
+[[box]]
+name = "mistserver"
+release = "catalyst"
+
+[box.strategy]
+download = "bucket"
+project = "mistserver"
+`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	expected, err := ParseYamlManifest(filepath.Join(dir, "manifest.yaml"), false)
+	require.NoError(t, err)
+	for name := range files {
+		m, err := ParseYamlManifest(filepath.Join(dir, name), false)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, m, name)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.toml"), []byte("version = \n"), 0644))
+	_, err = ReadManifest(filepath.Join(dir, "broken.toml"), false)
+	require.ErrorContains(t, err, "invalid TOML manifest")
+}
+
+func TestTOMLManifestIssues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`version = "3.0"
+
+[[box]]
+name = "mistserver"
+release = "catalyst"
+
+[box.strategy]
+download = "bukcet"
+project = "mistserver"
+`), 0644))
+	_, err := ParseYamlManifest(path, false)
+	require.EqualError(t, err, "invalid manifest, run `catalyst manifest validate` for details:\n"+path+`:box[0].strategy.download: error: box[0].strategy.download: must be one of "bucket", "github"`)
+}
+
+func TestManifestFromStdin(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	os.Stdin = reader
+	_, err = writer.WriteString(`{"version": "3.0", "box": [{"name": "mistserver", "release": "catalyst", "strategy": {"download": "bucket", "project": "mistserver"}}]}`)
+	require.NoError(t, err)
+	writer.Close()
+
+	m, err := ParseYamlManifest("-", false)
+	require.NoError(t, err)
+	require.Equal(t, "catalyst", m.Box[0].Release)
+}
+
+func TestSameCommit(t *testing.T) {
+	require.True(t, SameCommit("0846fae", "0846fae2b4c1e1d7a6d8c3e1f0a9b8c7d6e5f4a3"))
+	require.True(t, SameCommit("0846fae2b4c1e1d7a6d8c3e1f0a9b8c7d6e5f4a3", "0846fae"))
+	require.False(t, SameCommit("0846fae", "0846fbe"))
+	require.False(t, SameCommit("0846f", "0846fae2b4c1e1d7a6d8c3e1f0a9b8c7d6e5f4a3"), "too short to tell commits apart")
+	require.False(t, SameCommit("0", "0846fae"))
+	require.False(t, SameCommit("", "0846fae"))
+	require.False(t, SameCommit("main-br", "main-branch"), "not a commit hash")
+}
+
+func TestIsSupportedPlatformArch(t *testing.T) {
+	require.True(t, IsSupportedPlatformArch("linux", "arm64"))
+	require.True(t, IsSupportedPlatformArch("windows", "arm64"))
+	require.False(t, IsSupportedPlatformArch("windows", "386"))
+	require.False(t, IsSupportedPlatformArch("freebsd", "amd64"))
+}
@@ -0,0 +1,213 @@
+package verification
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	glog "github.com/magicsong/color-glog"
+)
+
+var (
+	elfMachines = map[string]elf.Machine{
+		"amd64": elf.EM_X86_64,
+		"arm64": elf.EM_AARCH64,
+	}
+	elfLoaders = map[string][]string{
+		"amd64": {"/lib64/ld-linux-x86-64.so.2", "/lib/ld-musl-x86_64.so.1"},
+		"arm64": {"/lib/ld-linux-aarch64.so.1", "/lib/ld-musl-aarch64.so.1"},
+	}
+	machoCPUs = map[string]macho.Cpu{
+		"amd64": macho.CpuAmd64,
+		"arm64": macho.CpuArm64,
+	}
+	peMachines = map[string]uint16{
+		"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+		"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+	}
+)
+
+// VerifyBinaryPlatform raises an error if an extracted file is an
+// executable that won't run on the given platform and architecture.
+// Shell scripts and non-executable data files are left alone. When
+// maxGlibc is set, linux binaries requiring a newer glibc are rejected.
+func VerifyBinaryPlatform(fileName, platform, architecture, maxGlibc string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	var format string
+	switch {
+	case bytes.HasPrefix(magic, []byte(elf.ELFMAG)):
+		format = "linux"
+	case isMachO(magic):
+		format = "darwin"
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		format = "windows"
+	case bytes.HasPrefix(magic, []byte("#!")):
+		glog.V(7).Infof("skipping platform check for script %q", fileName)
+		return nil
+	default:
+		if info.Mode()&0111 != 0 && platform != "windows" {
+			return fmt.Errorf("%s is executable but neither an ELF, Mach-O nor PE binary", fileName)
+		}
+		glog.V(7).Infof("skipping platform check for data file %q", fileName)
+		return nil
+	}
+	if format != platform {
+		return fmt.Errorf("%s is a %s binary, expected one for %s", fileName, format, platform)
+	}
+
+	switch format {
+	case "linux":
+		err = verifyELF(fileName, architecture, maxGlibc)
+	case "darwin":
+		err = verifyMachO(fileName, architecture)
+	case "windows":
+		err = verifyPE(fileName, architecture)
+	}
+	if err != nil {
+		return err
+	}
+	glog.V(5).Infof("%s is a valid %s-%s binary", fileName, platform, architecture)
+	return nil
+}
+
+func verifyELF(fileName, architecture, maxGlibc string) error {
+	file, err := elf.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if file.Class != elf.ELFCLASS64 || file.Machine != elfMachines[architecture] {
+		return fmt.Errorf("%s is a %s %s binary, expected %s", fileName, file.Class, file.Machine, architecture)
+	}
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		interp, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return err
+		}
+		loader := strings.TrimRight(string(interp), "\x00")
+		if !contains(elfLoaders[architecture], loader) {
+			return fmt.Errorf("%s requests dynamic loader %s which doesn't exist on linux-%s", fileName, loader, architecture)
+		}
+	}
+	// Statically linked binaries don't have any dynamic symbols
+	symbols, err := file.ImportedSymbols()
+	if err != nil {
+		return nil
+	}
+	required := ""
+	for _, symbol := range symbols {
+		version := strings.TrimPrefix(symbol.Version, "GLIBC_")
+		if version == symbol.Version {
+			continue
+		}
+		if compareVersions(version, required) > 0 {
+			required = version
+		}
+	}
+	if required == "" {
+		return nil
+	}
+	glog.V(6).Infof("%s requires glibc %s", fileName, required)
+	if maxGlibc != "" && compareVersions(required, maxGlibc) > 0 {
+		return fmt.Errorf("%s requires glibc %s but at most %s is available", fileName, required, maxGlibc)
+	}
+	return nil
+}
+
+func verifyMachO(fileName, architecture string) error {
+	if fat, err := macho.OpenFat(fileName); err == nil {
+		defer fat.Close()
+		for _, arch := range fat.Arches {
+			if arch.Cpu == machoCPUs[architecture] {
+				return nil
+			}
+		}
+		return fmt.Errorf("universal binary %s has no %s slice", fileName, architecture)
+	}
+	file, err := macho.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if file.Cpu != machoCPUs[architecture] {
+		return fmt.Errorf("%s is a %s binary, expected %s", fileName, file.Cpu, architecture)
+	}
+	return nil
+}
+
+func verifyPE(fileName, architecture string) error {
+	file, err := pe.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if file.Machine != peMachines[architecture] {
+		return fmt.Errorf("%s is built for machine type %#x, expected %s", fileName, file.Machine, architecture)
+	}
+	return nil
+}
+
+func isMachO(magic []byte) bool {
+	for _, m := range []uint32{macho.Magic32, macho.Magic64, macho.MagicFat} {
+		var be, le [4]byte
+		be[0], be[1], be[2], be[3] = byte(m>>24), byte(m>>16), byte(m>>8), byte(m)
+		le[0], le[1], le[2], le[3] = byte(m), byte(m>>8), byte(m>>16), byte(m>>24)
+		if bytes.Equal(magic, be[:]) || bytes.Equal(magic, le[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions like 2.31 and 2.4.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func contains(list []string, item string) bool {
+	for _, element := range list {
+		if element == item {
+			return true
+		}
+	}
+	return false
+}
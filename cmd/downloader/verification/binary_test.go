@@ -0,0 +1,73 @@
+package verification
+
+import (
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBinaryPlatform(t *testing.T) {
+	self, err := os.Executable()
+	require.NoError(t, err)
+	require.NoError(t, VerifyBinaryPlatform(self, runtime.GOOS, runtime.GOARCH, ""))
+
+	otherArch := "arm64"
+	if runtime.GOARCH == "arm64" {
+		otherArch = "amd64"
+	}
+	require.Error(t, VerifyBinaryPlatform(self, runtime.GOOS, otherArch, ""))
+	otherPlatform := "windows"
+	if runtime.GOOS == "windows" {
+		otherPlatform = "linux"
+	}
+	require.Error(t, VerifyBinaryPlatform(self, otherPlatform, runtime.GOARCH, ""))
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "mist-cleanup")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho ok\n"), 0755))
+	require.NoError(t, VerifyBinaryPlatform(script, "linux", "amd64", ""))
+
+	data := filepath.Join(dir, "README")
+	require.NoError(t, os.WriteFile(data, []byte("hello"), 0644))
+	require.NoError(t, VerifyBinaryPlatform(data, "linux", "amd64", ""))
+	require.NoError(t, os.Chmod(data, 0755))
+	require.Error(t, VerifyBinaryPlatform(data, "linux", "amd64", ""))
+}
+
+func TestVerifyBinaryGlibc(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("glibc checks only apply to linux binaries")
+	}
+	if !requiresGlibc("/bin/ls") {
+		t.Skip("no glibc linked binary to inspect, e.g. on musl or busybox systems")
+	}
+	require.NoError(t, VerifyBinaryPlatform("/bin/ls", "linux", runtime.GOARCH, "99.0"))
+	require.Error(t, VerifyBinaryPlatform("/bin/ls", "linux", runtime.GOARCH, "2.0"))
+}
+
+// requiresGlibc reports whether a binary imports versioned glibc symbols.
+func requiresGlibc(fileName string) bool {
+	file, err := elf.Open(fileName)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	symbols, _ := file.ImportedSymbols()
+	for _, symbol := range symbols {
+		if strings.HasPrefix(symbol.Version, "GLIBC_") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompareVersions(t *testing.T) {
+	require.Equal(t, 1, compareVersions("2.34", "2.4"))
+	require.Equal(t, -1, compareVersions("2.2.5", "2.17"))
+	require.Equal(t, 0, compareVersions("2.31", "2.31.0"))
+}
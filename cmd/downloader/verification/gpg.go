@@ -1,6 +1,7 @@
 package verification
 
 import (
+	"bytes"
 	"io/ioutil"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
@@ -8,33 +9,27 @@ import (
 	glog "github.com/magicsong/color-glog"
 )
 
+// PublicKey is the armored key that artifacts and remote manifests must
+// be signed with.
+var PublicKey = constants.PGPPublicKey
+
 // VerifyGPGSignature raises an error if provided `.sig` file is not
 // valid GPG signature for the given file.
 func VerifyGPGSignature(fileName, signatureFileName string) error {
-	// Generate keyring for the key fingerprint A2F9039A8603C44C21414432A2224D4537874DB2
-	key, err := crypto.NewKeyFromArmored(constants.PGPPublicKey)
-	if err != nil {
-		glog.Error(err)
-		return err
-	}
-	keyRing, err := crypto.NewKeyRing(key)
+	// Read GPG binary signature file
+	signature, err := ioutil.ReadFile(signatureFileName)
 	if err != nil {
-		glog.Error(err)
 		return err
 	}
-	glog.V(7).Info("GPG keyring initialised. Proceeding to load signature now!")
-
-	// Read GPG binary signature file
-	reader, err := ioutil.ReadFile(signatureFileName)
-	signature := crypto.NewPGPSignature(reader)
 	glog.V(7).Info("GPG signature read success!")
 
 	// Load signed file to memory as a binary message
 	data, err := ioutil.ReadFile(fileName)
-	message := crypto.NewPlainMessage(data)
+	if err != nil {
+		return err
+	}
 
-	// Verification step
-	err = keyRing.VerifyDetached(message, signature, crypto.GetUnixTime())
+	err = VerifyDetachedSignature(data, signature)
 	if err != nil {
 		glog.Errorf("GPG verification failed for %q with error %s", fileName, err)
 		return err
@@ -42,3 +37,26 @@ func VerifyGPGSignature(fileName, signatureFileName string) error {
 	glog.Info("GPG verification successful.")
 	return nil
 }
+
+// VerifyDetachedSignature raises an error if signature, binary or
+// armored, isn't a valid signature of data by PublicKey.
+func VerifyDetachedSignature(data, signature []byte) error {
+	// Generate keyring for the key fingerprint A2F9039A8603C44C21414432A2224D4537874DB2
+	key, err := crypto.NewKeyFromArmored(PublicKey)
+	if err != nil {
+		return err
+	}
+	keyRing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		return err
+	}
+	glog.V(7).Info("GPG keyring initialised. Proceeding to load signature now!")
+
+	pgpSignature := crypto.NewPGPSignature(signature)
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		if pgpSignature, err = crypto.NewPGPSignatureFromArmored(string(signature)); err != nil {
+			return err
+		}
+	}
+	return keyRing.VerifyDetached(crypto.NewPlainMessage(data), pgpSignature, crypto.GetUnixTime())
+}
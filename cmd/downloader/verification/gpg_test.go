@@ -0,0 +1,41 @@
+package verification
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	key, err := crypto.GenerateKey("catalyst test", "test@example.com", "x25519", 0)
+	require.NoError(t, err)
+	armored, err := key.GetArmoredPublicKey()
+	require.NoError(t, err)
+	defer func(previous string) { PublicKey = previous }(PublicKey)
+	PublicKey = armored
+
+	keyRing, err := crypto.NewKeyRing(key)
+	require.NoError(t, err)
+	data := []byte("version: \"3.0\"\nbox: []\n")
+	signature, err := keyRing.SignDetached(crypto.NewPlainMessage(data))
+	require.NoError(t, err)
+	armoredSignature, err := signature.GetArmored()
+	require.NoError(t, err)
+
+	require.NoError(t, VerifyDetachedSignature(data, signature.GetBinary()))
+	require.NoError(t, VerifyDetachedSignature(data, []byte(armoredSignature)))
+	require.Error(t, VerifyDetachedSignature([]byte("version: \"3.0\"\nbox: [evil]\n"), signature.GetBinary()))
+
+	// Signed by another key
+	PublicKey = otherKey(t)
+	require.Error(t, VerifyDetachedSignature(data, signature.GetBinary()))
+}
+
+func otherKey(t *testing.T) string {
+	other, err := crypto.GenerateKey("someone else", "else@example.com", "x25519", 0)
+	require.NoError(t, err)
+	armored, err := other.GetArmoredPublicKey()
+	require.NoError(t, err)
+	return armored
+}
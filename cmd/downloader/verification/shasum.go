@@ -1,7 +1,13 @@
 package verification
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"strings"
 
 	glog "github.com/magicsong/color-glog"
 )
@@ -21,3 +27,39 @@ func VerifySHA256Digest(directory, checksumFile string) error {
 	}
 	return nil
 }
+
+// VerifySize checks that a file has the size published for it.
+func VerifySize(fileName string, size int64) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", fileName, size, info.Size())
+	}
+	return nil
+}
+
+// VerifyDigest checks a file against a `sha256:<hex>` digest as pinned
+// in the artifacts of a manifest.
+func VerifyDigest(fileName, digest string) error {
+	algorithm, expected, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %q, expected sha256:<hex>", digest)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("digest mismatch for %s: expected sha256:%s, got sha256:%s", fileName, expected, actual)
+	}
+	glog.V(5).Infof("digest of %s matches", fileName)
+	return nil
+}
@@ -5,6 +5,7 @@ go 1.19
 replace github.com/testcontainers/testcontainers-go v0.26.0 => github.com/lefinal/testcontainers-go v0.0.0-20231107224233-ca049655293f
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/ProtonMail/gopenpgp/v2 v2.4.10
 	github.com/golang/glog v1.2.1
 	github.com/livepeer/stream-tester v0.12.30-0.20240619182724-f98674f33674